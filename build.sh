@@ -1,3 +1,3 @@
-rm -rf ./data && mkdir data
+mkdir -p data && rm -f ./data/*.db
 go build -o ./build/miner ./cmd/miner_client.go
-go build -o ./build/user ./cmd/user_client.go
\ No newline at end of file
+go build -o ./build/user ./cmd/user_client.go
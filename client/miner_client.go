@@ -1,27 +1,36 @@
 package client
 
 import (
+	"context"
+	"encoding/json"
 	"fmt"
 	"github.com/XiaoYao-0/memory-blockchain/common"
 	"github.com/XiaoYao-0/memory-blockchain/core"
+	"github.com/XiaoYao-0/memory-blockchain/keystore"
 	"github.com/c-bata/go-prompt"
 	"github.com/urfave/cli/v2"
+	"io/ioutil"
+	"math"
+	"runtime"
 	"strings"
 	"time"
 )
 
 type MinerClient struct {
-	BC            *core.Blockchain
-	App           *cli.App
-	Miner         common.Address
-	IsMinerSet    bool
-	IsMining      bool
-	MiningChannel chan struct{}
+	BC           *core.Blockchain
+	KeyStore     *keystore.KeyStore
+	App          *cli.App
+	Miner        common.Address
+	IsMinerSet   bool
+	IsMining     bool
+	cancelMining context.CancelFunc // stop the mining goroutine
+	miningDone   chan struct{}      // closed when the mining goroutine exits
 }
 
-func NewMinerClient(bc *core.Blockchain) *MinerClient {
+func NewMinerClient(bc *core.Blockchain, ks *keystore.KeyStore) *MinerClient {
 	mCli := &MinerClient{
-		BC: bc,
+		BC:       bc,
+		KeyStore: ks,
 	}
 	mCli.App = &cli.App{
 		Name: "blockchain miner client",
@@ -32,7 +41,7 @@ func NewMinerClient(bc *core.Blockchain) *MinerClient {
 				Flags: []cli.Flag{
 					&cli.StringFlag{
 						Name:     "miner",
-						Usage:    "miner",
+						Usage:    "miner, an unlocked account of a signer for the proof-of-authority engine (with prefix \"0x\")",
 						Required: true,
 					},
 				},
@@ -44,8 +53,16 @@ func NewMinerClient(bc *core.Blockchain) *MinerClient {
 				Action: mCli.getMinerAction(),
 			},
 			{
-				Name:   "startmining",
-				Usage:  "start mining",
+				Name:  "startmining",
+				Usage: "start mining",
+				Flags: []cli.Flag{
+					&cli.IntFlag{
+						Name:     "threads",
+						Usage:    "number of goroutines doing proof-of-work",
+						Value:    runtime.NumCPU(),
+						Required: false,
+					},
+				},
 				Action: mCli.startMiningAction(),
 			},
 			{
@@ -54,8 +71,20 @@ func NewMinerClient(bc *core.Blockchain) *MinerClient {
 				Action: mCli.endMiningAction(),
 			},
 			{
-				Name:   "printchain",
-				Usage:  "print data of blocks of the blockchain",
+				Name:  "printchain",
+				Usage: "print data of blocks of the blockchain, from the tip to genesis or in a range of heights",
+				Flags: []cli.Flag{
+					&cli.Int64Flag{
+						Name:     "from",
+						Usage:    "the lowest height to print",
+						Required: false,
+					},
+					&cli.Int64Flag{
+						Name:     "to",
+						Usage:    "the highest height to print, default to the height of the tip",
+						Required: false,
+					},
+				},
 				Action: mCli.printChainAction(),
 			},
 			{
@@ -65,12 +94,17 @@ func NewMinerClient(bc *core.Blockchain) *MinerClient {
 			},
 			{
 				Name:  "getblock",
-				Usage: "get a block by hash",
+				Usage: "get a block by hash or by height on the main chain",
 				Flags: []cli.Flag{
 					&cli.StringFlag{
 						Name:     "hash",
 						Usage:    "hash of a block (with prefix \"0x\")",
-						Required: true,
+						Required: false,
+					},
+					&cli.Int64Flag{
+						Name:     "height",
+						Usage:    "height of a block on the main chain",
+						Required: false,
 					},
 				},
 				Action: mCli.getBlockAction(),
@@ -93,12 +127,12 @@ func NewMinerClient(bc *core.Blockchain) *MinerClient {
 				Flags: []cli.Flag{
 					&cli.StringFlag{
 						Name:     "from",
-						Usage:    "hash of a transaction (with prefix \"0x\")",
+						Usage:    "address of an unlocked account (with prefix \"0x\")",
 						Required: true,
 					},
 					&cli.StringFlag{
 						Name:     "to",
-						Usage:    "hash of a transaction (with prefix \"0x\")",
+						Usage:    "address of the receiver (with prefix \"0x\")",
 						Required: true,
 					},
 					&cli.StringFlag{
@@ -111,9 +145,70 @@ func NewMinerClient(bc *core.Blockchain) *MinerClient {
 						Usage:    "amount you want to transfer",
 						Required: false,
 					},
+					&cli.Int64Flag{
+						Name:     "fee",
+						Usage:    "fee you offer, higher fees are packaged first (default the minimum fee)",
+						Required: false,
+					},
+					&cli.Int64Flag{
+						Name:     "validuntil",
+						Usage:    "last block height, or Unix timestamp if not less than 500000000, to package the transaction (default never expires)",
+						Required: false,
+					},
 				},
 				Action: mCli.sendTransactionAction(),
 			},
+			{
+				Name:   "newaccount",
+				Usage:  "create a new account in the keystore, the passphrase is read from the terminal",
+				Action: mCli.newAccountAction(),
+			},
+			{
+				Name:   "listaccounts",
+				Usage:  "list accounts in the keystore",
+				Action: mCli.listAccountsAction(),
+			},
+			{
+				Name:   "importkey",
+				Usage:  "import a private key into the keystore, the key and the passphrase are read from the terminal",
+				Action: mCli.importKeyAction(),
+			},
+			{
+				Name:  "exportkey",
+				Usage: "print the private key of an account in the keystore, the passphrase is read from the terminal",
+				Flags: []cli.Flag{
+					&cli.StringFlag{
+						Name:     "addr",
+						Usage:    "address of account (with prefix \"0x\")",
+						Required: true,
+					},
+				},
+				Action: mCli.exportKeyAction(),
+			},
+			{
+				Name:  "unlock",
+				Usage: "unlock an account to sign transactions, the passphrase is read from the terminal",
+				Flags: []cli.Flag{
+					&cli.StringFlag{
+						Name:     "addr",
+						Usage:    "address of account (with prefix \"0x\")",
+						Required: true,
+					},
+				},
+				Action: mCli.unlockAction(),
+			},
+			{
+				Name:  "lock",
+				Usage: "lock an unlocked account",
+				Flags: []cli.Flag{
+					&cli.StringFlag{
+						Name:     "addr",
+						Usage:    "address of account (with prefix \"0x\")",
+						Required: true,
+					},
+				},
+				Action: mCli.lockAction(),
+			},
 			{
 				Name:  "getaccount",
 				Usage: "get an account by address",
@@ -126,6 +221,195 @@ func NewMinerClient(bc *core.Blockchain) *MinerClient {
 				},
 				Action: mCli.getAccountAction(),
 			},
+			{
+				Name:  "getproof",
+				Usage: "get the Merkle inclusion proof of a packaged transaction",
+				Flags: []cli.Flag{
+					&cli.StringFlag{
+						Name:     "hash",
+						Usage:    "hash of a transaction (with prefix \"0x\")",
+						Required: true,
+					},
+					&cli.StringFlag{
+						Name:     "out",
+						Usage:    "file to export the proof to",
+						Required: false,
+					},
+				},
+				Action: mCli.getProofAction(),
+			},
+			{
+				Name:  "verifyproof",
+				Usage: "verify a Merkle inclusion proof offline",
+				Flags: []cli.Flag{
+					&cli.StringFlag{
+						Name:     "file",
+						Usage:    "file of the proof",
+						Required: true,
+					},
+					&cli.StringFlag{
+						Name:     "block",
+						Usage:    "hash of the block you trust (with prefix \"0x\")",
+						Required: false,
+					},
+				},
+				Action: mCli.verifyProofAction(),
+			},
+			{
+				Name:   "verifychain",
+				Usage:  "re-check every block from genesis to tip and re-execute them against a fresh state",
+				Action: mCli.verifyChainAction(),
+			},
+			{
+				Name:  "exportblock",
+				Usage: "export a block to a file to be imported by other nodes",
+				Flags: []cli.Flag{
+					&cli.StringFlag{
+						Name:     "hash",
+						Usage:    "hash of a block (with prefix \"0x\")",
+						Required: true,
+					},
+					&cli.StringFlag{
+						Name:     "out",
+						Usage:    "file to export the block to",
+						Required: true,
+					},
+				},
+				Action: mCli.exportBlockAction(),
+			},
+			{
+				Name:  "importblock",
+				Usage: "import a block exported by another node, the heaviest chain becomes the main chain",
+				Flags: []cli.Flag{
+					&cli.StringFlag{
+						Name:     "file",
+						Usage:    "file of the block",
+						Required: true,
+					},
+				},
+				Action: mCli.importBlockAction(),
+			},
+			{
+				Name:   "supply",
+				Usage:  "print coins allocated by genesis, minted, burned and circulating on the main chain",
+				Action: mCli.supplyAction(),
+			},
+			{
+				Name:  "benchmark",
+				Usage: "measure the hash rate of every proof-of-work algorithm",
+				Flags: []cli.Flag{
+					&cli.IntFlag{
+						Name:     "threads",
+						Usage:    "number of goroutines doing proof-of-work",
+						Value:    runtime.NumCPU(),
+						Required: false,
+					},
+					&cli.IntFlag{
+						Name:     "seconds",
+						Usage:    "seconds to measure each algorithm",
+						Value:    3,
+						Required: false,
+					},
+				},
+				Action: mCli.benchmarkAction(),
+			},
+			{
+				Name:  "rewind",
+				Usage: "undo the blocks above a height and return their transactions to Txs-Pool",
+				Flags: []cli.Flag{
+					&cli.Int64Flag{
+						Name:     "height",
+						Usage:    "height of the block to become the tip",
+						Required: true,
+					},
+				},
+				Action: mCli.rewindAction(),
+			},
+			{
+				Name:   "checkpoint",
+				Usage:  "print a checkpoint of the tip which can be added to \"checkpoints\" of the config in genesis.json",
+				Action: mCli.checkpointAction(),
+			},
+			{
+				Name:  "estimatefee",
+				Usage: "suggest a fee from the fee rates of recent blocks and the Txs-Pool",
+				Flags: []cli.Flag{
+					&cli.IntFlag{
+						Name:     "blocks",
+						Usage:    "number of recent blocks to look at",
+						Value:    core.DefaultFeeEstimateBlocks,
+						Required: false,
+					},
+					&cli.Int64Flag{
+						Name:     "size",
+						Usage:    "serialized size in bytes of the transaction to be sent",
+						Value:    core.DefaultFeeEstimateTxSize,
+						Required: false,
+					},
+				},
+				Action: mCli.estimateFeeAction(),
+			},
+			{
+				Name:  "bumpfee",
+				Usage: "replace a pending transaction with the same one paying a higher fee",
+				Flags: []cli.Flag{
+					&cli.StringFlag{
+						Name:     "hash",
+						Usage:    "hash of the transaction in Txs-Pool (with prefix \"0x\")",
+						Required: true,
+					},
+					&cli.Int64Flag{
+						Name:     "fee",
+						Usage:    "new fee, more than the pending one (default the higher of the suggested fee and the pending fee+1)",
+						Required: false,
+					},
+				},
+				Action: mCli.bumpFeeAction(),
+			},
+			{
+				Name:  "canceltx",
+				Usage: "cancel a pending transaction with a zero-value transfer to yourself paying a higher fee",
+				Flags: []cli.Flag{
+					&cli.StringFlag{
+						Name:     "hash",
+						Usage:    "hash of the transaction in Txs-Pool (with prefix \"0x\")",
+						Required: true,
+					},
+					&cli.Int64Flag{
+						Name:     "fee",
+						Usage:    "new fee, more than the pending one (default the higher of the suggested fee and the pending fee+1)",
+						Required: false,
+					},
+				},
+				Action: mCli.cancelTxAction(),
+			},
+			{
+				Name:  "sendmany",
+				Usage: "send one transaction paying all outputs in a CSV (to,amount[,message]) or JSON file",
+				Flags: []cli.Flag{
+					&cli.StringFlag{
+						Name:     "from",
+						Usage:    "address of an unlocked account (with prefix \"0x\")",
+						Required: true,
+					},
+					&cli.StringFlag{
+						Name:     "file",
+						Usage:    "CSV file of lines to,amount[,message], or JSON file (.json) of [{\"to\", \"amount\", \"message\"}]",
+						Required: true,
+					},
+					&cli.Int64Flag{
+						Name:     "fee",
+						Usage:    "fee you offer, higher fees are packaged first (default the minimum fee)",
+						Required: false,
+					},
+					&cli.Int64Flag{
+						Name:     "validuntil",
+						Usage:    "last block height, or Unix timestamp if not less than 500000000, to package the transaction (default never expires)",
+						Required: false,
+					},
+				},
+				Action: mCli.sendManyAction(),
+			},
 		},
 		ExitErrHandler: func(context *cli.Context, err error) {
 			if err != nil {
@@ -133,7 +417,6 @@ func NewMinerClient(bc *core.Blockchain) *MinerClient {
 			}
 		},
 	}
-	mCli.MiningChannel = make(chan struct{})
 	return mCli
 }
 
@@ -145,10 +428,29 @@ func minerCompleter(d prompt.Document) []prompt.Suggest {
 		{Text: "endmining", Description: "End mining"},
 		{Text: "printchain", Description: "Print data of blocks of the blockchain"},
 		{Text: "printtxspool", Description: "Print txs in Txs-Pool"},
-		{Text: "getblock", Description: "Get a block by hash"},
+		{Text: "getblock", Description: "Get a block by hash or height"},
 		{Text: "gettransaction", Description: "Get a transaction by hash"},
 		{Text: "sendtransaction", Description: "Send a transaction"},
+		{Text: "newaccount", Description: "Create a new account in the keystore"},
+		{Text: "listaccounts", Description: "List accounts in the keystore"},
+		{Text: "importkey", Description: "Import a private key into the keystore"},
+		{Text: "exportkey", Description: "Print the private key of an account in the keystore"},
+		{Text: "unlock", Description: "Unlock an account to sign transactions"},
+		{Text: "lock", Description: "Lock an unlocked account"},
 		{Text: "getaccount", Description: "Get an account by address"},
+		{Text: "getproof", Description: "Get the Merkle inclusion proof of a packaged transaction"},
+		{Text: "verifyproof", Description: "Verify a Merkle inclusion proof offline"},
+		{Text: "verifychain", Description: "Verify the whole chain and the accounts state"},
+		{Text: "exportblock", Description: "Export a block to a file"},
+		{Text: "importblock", Description: "Import a block exported by another node"},
+		{Text: "supply", Description: "Print minted, burned and circulating coins"},
+		{Text: "benchmark", Description: "Measure the hash rate of proof-of-work algorithms"},
+		{Text: "rewind", Description: "Undo the blocks above a height"},
+		{Text: "checkpoint", Description: "Print a checkpoint of the tip"},
+		{Text: "estimatefee", Description: "Suggest a fee for a new transaction"},
+		{Text: "bumpfee", Description: "Replace a pending transaction with a higher fee"},
+		{Text: "canceltx", Description: "Cancel a pending transaction"},
+		{Text: "sendmany", Description: "Send one transaction to many recipients listed in a file"},
 		{Text: "help", Description: "Print help docs"},
 		{Text: "exit", Description: "Exit the client"},
 	}
@@ -178,6 +480,16 @@ func (mCli *MinerClient) setMinerAction() func(c *cli.Context) error {
 		if err != nil {
 			return err
 		}
+		if poa, isPoA := mCli.BC.Engine.(*core.PoAEngine); isPoA {
+			key, err := mCli.KeyStore.UnlockedKey(miner)
+			if err != nil {
+				return fmt.Errorf("setMiner error: %v", err)
+			}
+			err = poa.Authorize(key)
+			if err != nil {
+				return err
+			}
+		}
 		mCli.Miner = miner
 		mCli.IsMinerSet = true
 		fmt.Printf("Miner is set to %v\n", miner.Hex(true))
@@ -203,21 +515,34 @@ func (mCli *MinerClient) startMiningAction() func(c *cli.Context) error {
 		if mCli.IsMining {
 			return fmt.Errorf("mining has been started")
 		}
+		threads := c.Int("threads")
+		if threads < 1 {
+			return fmt.Errorf("threads should be more than 0")
+		}
+		if pow, ok := mCli.BC.Engine.(*core.PowEngine); ok {
+			pow.SetThreads(threads)
+		}
+		ctx, cancel := context.WithCancel(context.Background())
+		mCli.cancelMining = cancel
+		mCli.miningDone = make(chan struct{})
 		mCli.IsMining = true
 		fmt.Println("Start Mining...")
-		go func(ch chan struct{}) {
+		go func(ctx context.Context, done chan struct{}) {
+			defer close(done)
 			for {
-				select {
-				case _ = <-ch:
+				err := mCli.BC.MineBlock(ctx, mCli.Miner)
+				if ctx.Err() != nil {
 					return
-				default:
-					err := mCli.BC.MineBlock(mCli.Miner)
-					if err != nil {
-						time.Sleep(time.Second * 10)
+				}
+				if err != nil && err != core.ErrMiningAborted {
+					select {
+					case <-ctx.Done():
+						return
+					case <-time.After(time.Second * 10):
 					}
 				}
 			}
-		}(mCli.MiningChannel)
+		}(ctx, mCli.miningDone)
 		return nil
 	}
 }
@@ -228,7 +553,10 @@ func (mCli *MinerClient) endMiningAction() func(c *cli.Context) error {
 			return fmt.Errorf("mining is not yet started")
 		}
 		fmt.Println("End Mining...")
-		mCli.MiningChannel <- struct{}{}
+		mCli.cancelMining()
+		<-mCli.miningDone
+		mCli.IsMining = false
+		fmt.Println("Mining is stopped")
 		return nil
 	}
 }
@@ -236,6 +564,24 @@ func (mCli *MinerClient) endMiningAction() func(c *cli.Context) error {
 func (mCli *MinerClient) printChainAction() func(c *cli.Context) error {
 	return func(c *cli.Context) error {
 		fmt.Println("Print chain...")
+		if c.IsSet("from") || c.IsSet("to") {
+			to := int64(math.MaxInt64)
+			if c.IsSet("to") {
+				to = c.Int64("to")
+			}
+			fbi, err := mCli.BC.ForwardBlocksIterator(c.Int64("from"), to)
+			if err != nil {
+				return err
+			}
+			for fbi.HasNext() {
+				block, err := fbi.Next()
+				if err != nil {
+					return err
+				}
+				fmt.Println(block.Output())
+			}
+			return nil
+		}
 		bci := mCli.BC.BlocksIterator()
 		for {
 			block, err := bci.Next()
@@ -243,7 +589,7 @@ func (mCli *MinerClient) printChainAction() func(c *cli.Context) error {
 				return err
 			}
 			fmt.Println(block.Output())
-			if block.PrevBlockHash == core.NewGenesisBlock().Hash {
+			if block.Header.Height == 0 {
 				fmt.Println("Genesis Block")
 				break
 			}
@@ -255,18 +601,30 @@ func (mCli *MinerClient) printChainAction() func(c *cli.Context) error {
 func (mCli *MinerClient) printTxsPoolAction() func(c *cli.Context) error {
 	return func(c *cli.Context) error {
 		fmt.Println("Print Txs-Pool...")
-		fmt.Println(mCli.BC.TxsPoolDB.TxsPool.Output())
+		fmt.Println(mCli.BC.TxsPoolDB.Output())
 		return nil
 	}
 }
 
 func (mCli *MinerClient) getBlockAction() func(c *cli.Context) error {
 	return func(c *cli.Context) error {
-		hash, err := common.NewHash(c.String("hash"))
-		if err != nil {
-			return fmt.Errorf("illegal hash error: %v", err)
+		var block *core.Block
+		var err error
+		switch {
+		case c.IsSet("hash") && c.IsSet("height"):
+			return fmt.Errorf("getBlock error: only one of --hash and --height can be set")
+		case c.IsSet("height"):
+			block, err = mCli.BC.BlocksDB.GetBlockByHeight(c.Int64("height"))
+		case c.IsSet("hash"):
+			var hash common.Hash
+			hash, err = common.NewHash(c.String("hash"))
+			if err != nil {
+				return fmt.Errorf("illegal hash error: %v", err)
+			}
+			block, err = mCli.BC.BlocksDB.GetBlock(hash)
+		default:
+			return fmt.Errorf("getBlock error: --hash or --height is required")
 		}
-		block, err := mCli.BC.BlocksDB.GetBlock(hash)
 		if err != nil {
 			return fmt.Errorf("getBlock error: %v", err)
 		}
@@ -292,7 +650,13 @@ func (mCli *MinerClient) getTransactionAction() func(c *cli.Context) error {
 
 		tx, err := mCli.BC.TransactionsDB.GetTransaction(hash)
 		if err != nil {
-			return fmt.Errorf("getBlock error: %v", err)
+			dropped, droppedErr := mCli.BC.TxsPoolDB.GetDroppedTx(hash)
+			if droppedErr != nil {
+				return fmt.Errorf("getBlock error: %v", err)
+			}
+			fmt.Printf("This transaction has been dropped from Txs-Pool: %v\n", dropped.Reason)
+			fmt.Println(dropped.Tx.Output())
+			return nil
 		}
 		fmt.Println("This transaction has been packaged.")
 		fmt.Println(tx.Output())
@@ -318,7 +682,25 @@ func (mCli *MinerClient) sendTransactionAction() func(c *cli.Context) error {
 		if amount < 0 {
 			return fmt.Errorf("amount should be more than 0")
 		}
-		tx, err := core.NewTransaction(from, to, message, amount)
+		if c.Int64("fee") < 0 {
+			return fmt.Errorf("fee should not be less than 0")
+		}
+		if c.Int64("validuntil") < 0 {
+			return fmt.Errorf("validuntil should not be less than 0")
+		}
+		nonce, err := mCli.BC.GetNextNonce(from)
+		if err != nil {
+			return fmt.Errorf("sendTransaction error: %v", err)
+		}
+		rules, err := mCli.BC.NextRules()
+		if err != nil {
+			return fmt.Errorf("sendTransaction error: %v", err)
+		}
+		tx, err := core.NewTransaction(rules, from, to, message, amount, c.Int64("fee"), c.Int64("validuntil"), nonce)
+		if err != nil {
+			return fmt.Errorf("sendTransaction error: %v", err)
+		}
+		err = mCli.KeyStore.SignTx(tx)
 		if err != nil {
 			return fmt.Errorf("sendTransaction error: %v", err)
 		}
@@ -344,3 +726,371 @@ func (mCli *MinerClient) getAccountAction() func(c *cli.Context) error {
 		return nil
 	}
 }
+
+func (mCli *MinerClient) getProofAction() func(c *cli.Context) error {
+	return func(c *cli.Context) error {
+		hash, err := common.NewHash(c.String("hash"))
+		if err != nil {
+			return fmt.Errorf("illegal hash error: %v", err)
+		}
+		proof, err := mCli.BC.GetTxProof(hash)
+		if err != nil {
+			return fmt.Errorf("getProof error: %v", err)
+		}
+		proofJSON, err := json.Marshal(proof)
+		if err != nil {
+			return fmt.Errorf("getProof error: %v", err)
+		}
+		fmt.Println(string(proofJSON))
+		if out := c.String("out"); out != "" {
+			err = ioutil.WriteFile(out, proofJSON, 0644)
+			if err != nil {
+				return fmt.Errorf("getProof error: %v", err)
+			}
+			fmt.Printf("Proof is exported to %v\n", out)
+		}
+		return nil
+	}
+}
+
+func (mCli *MinerClient) verifyProofAction() func(c *cli.Context) error {
+	return func(c *cli.Context) error {
+		proofJSON, err := ioutil.ReadFile(c.String("file"))
+		if err != nil {
+			return fmt.Errorf("verifyProof error: %v", err)
+		}
+		var proof core.TxProof
+		err = json.Unmarshal(proofJSON, &proof)
+		if err != nil {
+			return fmt.Errorf("verifyProof error: %v", err)
+		}
+		trustedBlockHash := proof.BlockHash()
+		if c.String("block") != "" {
+			trustedBlockHash, err = common.NewHash(c.String("block"))
+			if err != nil {
+				return fmt.Errorf("illegal block hash error: %v", err)
+			}
+		}
+		err = core.VerifyTxProof(&proof, trustedBlockHash)
+		if err != nil {
+			return fmt.Errorf("verifyProof error: %v", err)
+		}
+		fmt.Println(proof.Output())
+		if c.String("block") == "" {
+			fmt.Printf("✅ Proof is valid if you trust block %v\n", trustedBlockHash.Hex(true))
+		} else {
+			fmt.Println("✅ Proof is valid")
+		}
+		return nil
+	}
+}
+
+func (mCli *MinerClient) verifyChainAction() func(c *cli.Context) error {
+	return func(c *cli.Context) error {
+		count, err := mCli.BC.Verify()
+		if err != nil {
+			return fmt.Errorf("verifyChain error: %v", err)
+		}
+		fmt.Printf("✅ Chain is valid: %v blocks verified\n", count)
+		return nil
+	}
+}
+
+func (mCli *MinerClient) exportBlockAction() func(c *cli.Context) error {
+	return func(c *cli.Context) error {
+		hash, err := common.NewHash(c.String("hash"))
+		if err != nil {
+			return fmt.Errorf("illegal hash error: %v", err)
+		}
+		block, err := mCli.BC.BlocksDB.GetBlock(hash)
+		if err != nil {
+			return fmt.Errorf("exportBlock error: %v", err)
+		}
+		err = ioutil.WriteFile(c.String("out"), block.Serialize(), 0644)
+		if err != nil {
+			return fmt.Errorf("exportBlock error: %v", err)
+		}
+		fmt.Printf("Block %v is exported to %v\n", hash.Hex(true), c.String("out"))
+		return nil
+	}
+}
+
+func (mCli *MinerClient) importBlockAction() func(c *cli.Context) error {
+	return func(c *cli.Context) error {
+		encodedBlock, err := ioutil.ReadFile(c.String("file"))
+		if err != nil {
+			return fmt.Errorf("importBlock error: %v", err)
+		}
+		block, err := core.DeserializeBlock(encodedBlock)
+		if err != nil {
+			return fmt.Errorf("importBlock error: %v", err)
+		}
+		isTip, err := mCli.BC.InsertBlock(block)
+		if err != nil {
+			return fmt.Errorf("importBlock error: %v", err)
+		}
+		if isTip {
+			fmt.Printf("✅ Block %v is imported as the new tip\n", block.Hash.Hex(true))
+		} else {
+			fmt.Printf("✅ Block %v is imported on a side chain\n", block.Hash.Hex(true))
+		}
+		return nil
+	}
+}
+
+func (mCli *MinerClient) supplyAction() func(c *cli.Context) error {
+	return func(c *cli.Context) error {
+		supply, err := mCli.BC.GetSupply()
+		if err != nil {
+			return fmt.Errorf("supply error: %v", err)
+		}
+		fmt.Println(supply.Output())
+		return nil
+	}
+}
+
+func (mCli *MinerClient) benchmarkAction() func(c *cli.Context) error {
+	return func(c *cli.Context) error {
+		if mCli.IsMining {
+			return fmt.Errorf("benchmark error: please end mining first")
+		}
+		duration := time.Duration(c.Int("seconds")) * time.Second
+		if duration <= 0 {
+			return fmt.Errorf("benchmark error: seconds should be more than 0")
+		}
+		active := mCli.BC.Config.Rules(math.MaxInt64).NewPowAlgorithm().Name
+		for _, algorithm := range core.PowAlgorithms(mCli.BC.Config) {
+			rate := core.BenchmarkPow(algorithm, c.Int("threads"), duration)
+			if algorithm.Name == active {
+				fmt.Printf("📊 %v: %.0f H/s (configured)\n", algorithm.Name, rate)
+			} else {
+				fmt.Printf("📊 %v: %.0f H/s\n", algorithm.Name, rate)
+			}
+		}
+		return nil
+	}
+}
+
+func (mCli *MinerClient) rewindAction() func(c *cli.Context) error {
+	return func(c *cli.Context) error {
+		count, err := mCli.BC.RewindTo(c.Int64("height"))
+		if err != nil {
+			return fmt.Errorf("rewind error: %v", err)
+		}
+		fmt.Printf("⏪ %v blocks are undone, the tip is at height %v now\n", count, c.Int64("height"))
+		return nil
+	}
+}
+
+func (mCli *MinerClient) checkpointAction() func(c *cli.Context) error {
+	return func(c *cli.Context) error {
+		checkpoint, err := mCli.BC.GetCheckpoint()
+		if err != nil {
+			return fmt.Errorf("checkpoint error: %v", err)
+		}
+		fmt.Println(checkpoint)
+		return nil
+	}
+}
+
+func (mCli *MinerClient) estimateFeeAction() func(c *cli.Context) error {
+	return func(c *cli.Context) error {
+		estimate, err := mCli.BC.EstimateFee(c.Int("blocks"), c.Int64("size"))
+		if err != nil {
+			return fmt.Errorf("estimateFee error: %v", err)
+		}
+		fmt.Println(estimate.Output())
+		return nil
+	}
+}
+
+func (mCli *MinerClient) bumpFeeAction() func(c *cli.Context) error {
+	return func(c *cli.Context) error {
+		hash, err := common.NewHash(c.String("hash"))
+		if err != nil {
+			return fmt.Errorf("illegal hash error: %v", err)
+		}
+		tx, err := mCli.BC.NewReplacementTx(hash, c.Int64("fee"))
+		if err != nil {
+			return fmt.Errorf("bumpFee error: %v", err)
+		}
+		err = mCli.KeyStore.SignTx(tx)
+		if err != nil {
+			return fmt.Errorf("bumpFee error: %v", err)
+		}
+		err = mCli.BC.SendTransaction(tx)
+		if err != nil {
+			return fmt.Errorf("bumpFee error: %v", err)
+		}
+		return nil
+	}
+}
+
+func (mCli *MinerClient) cancelTxAction() func(c *cli.Context) error {
+	return func(c *cli.Context) error {
+		hash, err := common.NewHash(c.String("hash"))
+		if err != nil {
+			return fmt.Errorf("illegal hash error: %v", err)
+		}
+		tx, err := mCli.BC.NewCancellationTx(hash, c.Int64("fee"))
+		if err != nil {
+			return fmt.Errorf("cancelTx error: %v", err)
+		}
+		err = mCli.KeyStore.SignTx(tx)
+		if err != nil {
+			return fmt.Errorf("cancelTx error: %v", err)
+		}
+		err = mCli.BC.SendTransaction(tx)
+		if err != nil {
+			return fmt.Errorf("cancelTx error: %v", err)
+		}
+		return nil
+	}
+}
+
+func (mCli *MinerClient) sendManyAction() func(c *cli.Context) error {
+	return func(c *cli.Context) error {
+		from, err := common.NewAddress(c.String("from"))
+		if err != nil {
+			return fmt.Errorf("illegal from address error: %v", err)
+		}
+		if c.Int64("fee") < 0 || c.Int64("validuntil") < 0 {
+			return fmt.Errorf("fee and validuntil should not be less than 0")
+		}
+		outputs, err := core.ReadTransferOutputs(c.String("file"))
+		if err != nil {
+			return fmt.Errorf("sendMany error: %v", err)
+		}
+		nonce, err := mCli.BC.GetNextNonce(from)
+		if err != nil {
+			return fmt.Errorf("sendMany error: %v", err)
+		}
+		rules, err := mCli.BC.NextRules()
+		if err != nil {
+			return fmt.Errorf("sendMany error: %v", err)
+		}
+		tx, err := core.NewMultiTransferTransaction(rules, from, outputs, c.Int64("fee"), c.Int64("validuntil"), nonce)
+		if err != nil {
+			return fmt.Errorf("sendMany error: %v", err)
+		}
+		err = mCli.KeyStore.SignTx(tx)
+		if err != nil {
+			return fmt.Errorf("sendMany error: %v", err)
+		}
+		err = mCli.BC.SendTransaction(tx)
+		if err != nil {
+			return fmt.Errorf("sendMany error: %v", err)
+		}
+		return nil
+	}
+}
+
+func (mCli *MinerClient) newAccountAction() func(c *cli.Context) error {
+	return func(c *cli.Context) error {
+		passphrase, err := readNewPassphrase()
+		if err != nil {
+			return fmt.Errorf("newAccount error: %v", err)
+		}
+		addr, err := mCli.KeyStore.NewAccount(passphrase)
+		if err != nil {
+			return fmt.Errorf("newAccount error: %v", err)
+		}
+		fmt.Printf("New account: %v\n", addr.Hex(true))
+		return nil
+	}
+}
+
+func (mCli *MinerClient) listAccountsAction() func(c *cli.Context) error {
+	return func(c *cli.Context) error {
+		addrs, err := mCli.KeyStore.Accounts()
+		if err != nil {
+			return fmt.Errorf("listAccounts error: %v", err)
+		}
+		fmt.Printf("%v accounts in keystore:\n", len(addrs))
+		for _, addr := range addrs {
+			status := "locked"
+			if mCli.KeyStore.IsUnlocked(addr) {
+				status = "unlocked"
+			}
+			fmt.Printf("  %v (%v)\n", addr.Hex(true), status)
+		}
+		return nil
+	}
+}
+
+func (mCli *MinerClient) importKeyAction() func(c *cli.Context) error {
+	return func(c *cli.Context) error {
+		keyHex, err := readSecret("Private key (with prefix \"0x\"): ")
+		if err != nil {
+			return fmt.Errorf("importKey error: %v", err)
+		}
+		key, err := common.NewPrivateKey(keyHex)
+		if err != nil {
+			return fmt.Errorf("illegal private key error: %v", err)
+		}
+		passphrase, err := readNewPassphrase()
+		if err != nil {
+			return fmt.Errorf("importKey error: %v", err)
+		}
+		addr, err := mCli.KeyStore.ImportKey(key, passphrase)
+		if err != nil {
+			return fmt.Errorf("importKey error: %v", err)
+		}
+		fmt.Printf("Imported account: %v\n", addr.Hex(true))
+		return nil
+	}
+}
+
+func (mCli *MinerClient) exportKeyAction() func(c *cli.Context) error {
+	return func(c *cli.Context) error {
+		addr, err := common.NewAddress(c.String("addr"))
+		if err != nil {
+			return fmt.Errorf("illegal address error: %v", err)
+		}
+		passphrase, err := readSecret("Passphrase: ")
+		if err != nil {
+			return fmt.Errorf("exportKey error: %v", err)
+		}
+		key, err := mCli.KeyStore.ExportKey(addr, passphrase)
+		if err != nil {
+			return fmt.Errorf("exportKey error: %v", err)
+		}
+		fmt.Printf("Private key: %v\n", common.PrivateKeyHex(key))
+		fmt.Println("Keep the private key safe, anyone who has it can spend your funds.")
+		return nil
+	}
+}
+
+func (mCli *MinerClient) unlockAction() func(c *cli.Context) error {
+	return func(c *cli.Context) error {
+		addr, err := common.NewAddress(c.String("addr"))
+		if err != nil {
+			return fmt.Errorf("illegal address error: %v", err)
+		}
+		passphrase, err := readSecret("Passphrase: ")
+		if err != nil {
+			return fmt.Errorf("unlock error: %v", err)
+		}
+		err = mCli.KeyStore.Unlock(addr, passphrase)
+		if err != nil {
+			return fmt.Errorf("unlock error: %v", err)
+		}
+		fmt.Printf("Account %v is unlocked\n", addr.Hex(true))
+		return nil
+	}
+}
+
+func (mCli *MinerClient) lockAction() func(c *cli.Context) error {
+	return func(c *cli.Context) error {
+		addr, err := common.NewAddress(c.String("addr"))
+		if err != nil {
+			return fmt.Errorf("illegal address error: %v", err)
+		}
+		err = mCli.KeyStore.Lock(addr)
+		if err != nil {
+			return fmt.Errorf("lock error: %v", err)
+		}
+		fmt.Printf("Account %v is locked\n", addr.Hex(true))
+		return nil
+	}
+}
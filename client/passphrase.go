@@ -0,0 +1,38 @@
+package client
+
+import (
+	"fmt"
+	"golang.org/x/term"
+	"os"
+)
+
+// readSecret read a line from the terminal without echoing it, so passphrases and private keys
+// never show on the screen or in the shell history
+func readSecret(prompt string) (string, error) {
+	fmt.Print(prompt)
+	secret, err := term.ReadPassword(int(os.Stdin.Fd()))
+	fmt.Println()
+	if err != nil {
+		return "", fmt.Errorf("readSecret error: %v", err)
+	}
+	return string(secret), nil
+}
+
+// readNewPassphrase read a passphrase twice for a new key file
+func readNewPassphrase() (string, error) {
+	passphrase, err := readSecret("Passphrase: ")
+	if err != nil {
+		return "", err
+	}
+	if passphrase == "" {
+		return "", fmt.Errorf("passphrase should not be empty")
+	}
+	repeated, err := readSecret("Repeat passphrase: ")
+	if err != nil {
+		return "", err
+	}
+	if repeated != passphrase {
+		return "", fmt.Errorf("passphrases do not match")
+	}
+	return passphrase, nil
+}
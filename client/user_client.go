@@ -1,30 +1,48 @@
 package client
 
 import (
+	"encoding/json"
 	"fmt"
 	"github.com/XiaoYao-0/memory-blockchain/common"
 	"github.com/XiaoYao-0/memory-blockchain/core"
+	"github.com/XiaoYao-0/memory-blockchain/keystore"
 	"github.com/c-bata/go-prompt"
 	"github.com/urfave/cli/v2"
+	"io/ioutil"
+	"math"
 	"strings"
 )
 
 type UserClient struct {
-	BC   *core.Blockchain
-	App  *cli.App
-	User common.Address
+	BC       *core.Blockchain
+	App      *cli.App
+	User     common.Address
+	KeyStore *keystore.KeyStore
 }
 
-func NewUserClient(bc *core.Blockchain) *UserClient {
+func NewUserClient(bc *core.Blockchain, ks *keystore.KeyStore) *UserClient {
 	uCli := &UserClient{
-		BC: bc,
+		BC:       bc,
+		KeyStore: ks,
 	}
 	uCli.App = &cli.App{
 		Name: "blockchain user client",
 		Commands: []*cli.Command{
 			{
-				Name:   "printchain",
-				Usage:  "print data of blocks of the blockchain",
+				Name:  "printchain",
+				Usage: "print data of blocks of the blockchain, from the tip to genesis or in a range of heights",
+				Flags: []cli.Flag{
+					&cli.Int64Flag{
+						Name:     "from",
+						Usage:    "the lowest height to print",
+						Required: false,
+					},
+					&cli.Int64Flag{
+						Name:     "to",
+						Usage:    "the highest height to print, default to the height of the tip",
+						Required: false,
+					},
+				},
 				Action: uCli.printChainAction(),
 			},
 			{
@@ -34,12 +52,17 @@ func NewUserClient(bc *core.Blockchain) *UserClient {
 			},
 			{
 				Name:  "getblock",
-				Usage: "get a block by hash",
+				Usage: "get a block by hash or by height on the main chain",
 				Flags: []cli.Flag{
 					&cli.StringFlag{
 						Name:     "hash",
 						Usage:    "hash of a block (with prefix \"0x\")",
-						Required: true,
+						Required: false,
+					},
+					&cli.Int64Flag{
+						Name:     "height",
+						Usage:    "height of a block on the main chain",
+						Required: false,
 					},
 				},
 				Action: uCli.getBlockAction(),
@@ -62,12 +85,12 @@ func NewUserClient(bc *core.Blockchain) *UserClient {
 				Flags: []cli.Flag{
 					&cli.StringFlag{
 						Name:     "from",
-						Usage:    "hash of a transaction (with prefix \"0x\")",
+						Usage:    "address of an unlocked account (with prefix \"0x\")",
 						Required: true,
 					},
 					&cli.StringFlag{
 						Name:     "to",
-						Usage:    "hash of a transaction (with prefix \"0x\")",
+						Usage:    "address of the receiver (with prefix \"0x\")",
 						Required: true,
 					},
 					&cli.StringFlag{
@@ -80,9 +103,240 @@ func NewUserClient(bc *core.Blockchain) *UserClient {
 						Usage:    "amount you want to transfer",
 						Required: false,
 					},
+					&cli.Int64Flag{
+						Name:     "fee",
+						Usage:    "fee you offer, higher fees are packaged first (default the minimum fee)",
+						Required: false,
+					},
+					&cli.Int64Flag{
+						Name:     "validuntil",
+						Usage:    "last block height, or Unix timestamp if not less than 500000000, to package the transaction (default never expires)",
+						Required: false,
+					},
 				},
 				Action: uCli.sendTransactionAction(),
 			},
+			{
+				Name:   "newaccount",
+				Usage:  "create a new account in the keystore, the passphrase is read from the terminal",
+				Action: uCli.newAccountAction(),
+			},
+			{
+				Name:   "listaccounts",
+				Usage:  "list accounts in the keystore",
+				Action: uCli.listAccountsAction(),
+			},
+			{
+				Name:   "importkey",
+				Usage:  "import a private key into the keystore, the key and the passphrase are read from the terminal",
+				Action: uCli.importKeyAction(),
+			},
+			{
+				Name:  "exportkey",
+				Usage: "print the private key of an account in the keystore, the passphrase is read from the terminal",
+				Flags: []cli.Flag{
+					&cli.StringFlag{
+						Name:     "addr",
+						Usage:    "address of account (with prefix \"0x\")",
+						Required: true,
+					},
+				},
+				Action: uCli.exportKeyAction(),
+			},
+			{
+				Name:  "unlock",
+				Usage: "unlock an account to sign transactions, the passphrase is read from the terminal",
+				Flags: []cli.Flag{
+					&cli.StringFlag{
+						Name:     "addr",
+						Usage:    "address of account (with prefix \"0x\")",
+						Required: true,
+					},
+				},
+				Action: uCli.unlockAction(),
+			},
+			{
+				Name:  "lock",
+				Usage: "lock an unlocked account",
+				Flags: []cli.Flag{
+					&cli.StringFlag{
+						Name:     "addr",
+						Usage:    "address of account (with prefix \"0x\")",
+						Required: true,
+					},
+				},
+				Action: uCli.lockAction(),
+			},
+			{
+				Name:  "getproof",
+				Usage: "get the Merkle inclusion proof of a packaged transaction",
+				Flags: []cli.Flag{
+					&cli.StringFlag{
+						Name:     "hash",
+						Usage:    "hash of a transaction (with prefix \"0x\")",
+						Required: true,
+					},
+					&cli.StringFlag{
+						Name:     "out",
+						Usage:    "file to export the proof to",
+						Required: false,
+					},
+				},
+				Action: uCli.getProofAction(),
+			},
+			{
+				Name:  "verifyproof",
+				Usage: "verify a Merkle inclusion proof offline",
+				Flags: []cli.Flag{
+					&cli.StringFlag{
+						Name:     "file",
+						Usage:    "file of the proof",
+						Required: true,
+					},
+					&cli.StringFlag{
+						Name:     "block",
+						Usage:    "hash of the block you trust (with prefix \"0x\")",
+						Required: false,
+					},
+				},
+				Action: uCli.verifyProofAction(),
+			},
+			{
+				Name:   "verifychain",
+				Usage:  "re-check every block from genesis to tip and re-execute them against a fresh state",
+				Action: uCli.verifyChainAction(),
+			},
+			{
+				Name:  "exportblock",
+				Usage: "export a block to a file to be imported by other nodes",
+				Flags: []cli.Flag{
+					&cli.StringFlag{
+						Name:     "hash",
+						Usage:    "hash of a block (with prefix \"0x\")",
+						Required: true,
+					},
+					&cli.StringFlag{
+						Name:     "out",
+						Usage:    "file to export the block to",
+						Required: true,
+					},
+				},
+				Action: uCli.exportBlockAction(),
+			},
+			{
+				Name:  "importblock",
+				Usage: "import a block exported by another node, the heaviest chain becomes the main chain",
+				Flags: []cli.Flag{
+					&cli.StringFlag{
+						Name:     "file",
+						Usage:    "file of the block",
+						Required: true,
+					},
+				},
+				Action: uCli.importBlockAction(),
+			},
+			{
+				Name:   "supply",
+				Usage:  "print coins allocated by genesis, minted, burned and circulating on the main chain",
+				Action: uCli.supplyAction(),
+			},
+			{
+				Name:  "rewind",
+				Usage: "undo the blocks above a height and return their transactions to Txs-Pool",
+				Flags: []cli.Flag{
+					&cli.Int64Flag{
+						Name:     "height",
+						Usage:    "height of the block to become the tip",
+						Required: true,
+					},
+				},
+				Action: uCli.rewindAction(),
+			},
+			{
+				Name:   "checkpoint",
+				Usage:  "print a checkpoint of the tip which can be added to \"checkpoints\" of the config in genesis.json",
+				Action: uCli.checkpointAction(),
+			},
+			{
+				Name:  "estimatefee",
+				Usage: "suggest a fee from the fee rates of recent blocks and the Txs-Pool",
+				Flags: []cli.Flag{
+					&cli.IntFlag{
+						Name:     "blocks",
+						Usage:    "number of recent blocks to look at",
+						Value:    core.DefaultFeeEstimateBlocks,
+						Required: false,
+					},
+					&cli.Int64Flag{
+						Name:     "size",
+						Usage:    "serialized size in bytes of the transaction to be sent",
+						Value:    core.DefaultFeeEstimateTxSize,
+						Required: false,
+					},
+				},
+				Action: uCli.estimateFeeAction(),
+			},
+			{
+				Name:  "bumpfee",
+				Usage: "replace a pending transaction with the same one paying a higher fee",
+				Flags: []cli.Flag{
+					&cli.StringFlag{
+						Name:     "hash",
+						Usage:    "hash of the transaction in Txs-Pool (with prefix \"0x\")",
+						Required: true,
+					},
+					&cli.Int64Flag{
+						Name:     "fee",
+						Usage:    "new fee, more than the pending one (default the higher of the suggested fee and the pending fee+1)",
+						Required: false,
+					},
+				},
+				Action: uCli.bumpFeeAction(),
+			},
+			{
+				Name:  "canceltx",
+				Usage: "cancel a pending transaction with a zero-value transfer to yourself paying a higher fee",
+				Flags: []cli.Flag{
+					&cli.StringFlag{
+						Name:     "hash",
+						Usage:    "hash of the transaction in Txs-Pool (with prefix \"0x\")",
+						Required: true,
+					},
+					&cli.Int64Flag{
+						Name:     "fee",
+						Usage:    "new fee, more than the pending one (default the higher of the suggested fee and the pending fee+1)",
+						Required: false,
+					},
+				},
+				Action: uCli.cancelTxAction(),
+			},
+			{
+				Name:  "sendmany",
+				Usage: "send one transaction paying all outputs in a CSV (to,amount[,message]) or JSON file",
+				Flags: []cli.Flag{
+					&cli.StringFlag{
+						Name:     "from",
+						Usage:    "address of an unlocked account (with prefix \"0x\")",
+						Required: true,
+					},
+					&cli.StringFlag{
+						Name:     "file",
+						Usage:    "CSV file of lines to,amount[,message], or JSON file (.json) of [{\"to\", \"amount\", \"message\"}]",
+						Required: true,
+					},
+					&cli.Int64Flag{
+						Name:     "fee",
+						Usage:    "fee you offer, higher fees are packaged first (default the minimum fee)",
+						Required: false,
+					},
+					&cli.Int64Flag{
+						Name:     "validuntil",
+						Usage:    "last block height, or Unix timestamp if not less than 500000000, to package the transaction (default never expires)",
+						Required: false,
+					},
+				},
+				Action: uCli.sendManyAction(),
+			},
 		},
 		ExitErrHandler: func(context *cli.Context, err error) {
 			if err != nil {
@@ -93,22 +347,74 @@ func NewUserClient(bc *core.Blockchain) *UserClient {
 	return uCli
 }
 
-func userCompleter(d prompt.Document) []prompt.Suggest {
+// addressFlags flags whose value can be completed with known addresses
+var addressFlags = map[string]bool{
+	"--from": true,
+	"--to":   true,
+	"--addr": true,
+}
+
+func (uCli *UserClient) userCompleter(d prompt.Document) []prompt.Suggest {
+	word := d.GetWordBeforeCursor()
+	fields := strings.Fields(d.TextBeforeCursor())
+	prevField := ""
+	if word == "" && len(fields) > 0 {
+		prevField = fields[len(fields)-1]
+	} else if len(fields) > 1 {
+		prevField = fields[len(fields)-2]
+	}
+	if addressFlags[prevField] || strings.HasPrefix(word, "0x") {
+		return prompt.FilterHasPrefix(uCli.addressSuggests(), word, true)
+	}
 	s := []prompt.Suggest{
 		{Text: "printchain", Description: "Print data of blocks of the blockchain"},
 		{Text: "printtxspool", Description: "Store the article text posted by user"},
-		{Text: "getblock", Description: "Get a block by hash"},
+		{Text: "getblock", Description: "Get a block by hash or height"},
 		{Text: "gettransaction", Description: "Get a transaction by hash"},
 		{Text: "sendtransaction", Description: "Send a transaction"},
+		{Text: "newaccount", Description: "Create a new account in the keystore"},
+		{Text: "listaccounts", Description: "List accounts in the keystore"},
+		{Text: "importkey", Description: "Import a private key into the keystore"},
+		{Text: "exportkey", Description: "Print the private key of an account in the keystore"},
+		{Text: "unlock", Description: "Unlock an account to sign transactions"},
+		{Text: "lock", Description: "Lock an unlocked account"},
+		{Text: "getproof", Description: "Get the Merkle inclusion proof of a packaged transaction"},
+		{Text: "verifyproof", Description: "Verify a Merkle inclusion proof offline"},
+		{Text: "verifychain", Description: "Verify the whole chain and the accounts state"},
+		{Text: "exportblock", Description: "Export a block to a file"},
+		{Text: "importblock", Description: "Import a block exported by another node"},
+		{Text: "supply", Description: "Print minted, burned and circulating coins"},
+		{Text: "rewind", Description: "Undo the blocks above a height"},
+		{Text: "checkpoint", Description: "Print a checkpoint of the tip"},
+		{Text: "estimatefee", Description: "Suggest a fee for a new transaction"},
+		{Text: "bumpfee", Description: "Replace a pending transaction with a higher fee"},
+		{Text: "canceltx", Description: "Cancel a pending transaction"},
+		{Text: "sendmany", Description: "Send one transaction to many recipients listed in a file"},
 		{Text: "help", Description: "Print help docs"},
 		{Text: "exit", Description: "Exit the client"},
 	}
-	return prompt.FilterHasPrefix(s, d.GetWordBeforeCursor(), true)
+	return prompt.FilterHasPrefix(s, word, true)
+}
+
+func (uCli *UserClient) addressSuggests() []prompt.Suggest {
+	addrs, err := uCli.KeyStore.Accounts()
+	if err != nil {
+		return []prompt.Suggest{}
+	}
+	s := make([]prompt.Suggest, len(addrs))
+	for i, addr := range addrs {
+		description := "locked"
+		if uCli.KeyStore.IsUnlocked(addr) {
+			description = "unlocked"
+		}
+		s[i] = prompt.Suggest{Text: addr.Hex(true), Description: description}
+	}
+	return s
 }
 
 func (uCli *UserClient) Run() {
 	for {
-		t := prompt.Input(">> ", userCompleter)
+		t := prompt.Input(">> ", uCli.userCompleter)
 		switch t {
 		case "help":
 			_ = uCli.App.Run([]string{"-h"})
@@ -123,6 +429,24 @@ func (uCli *UserClient) Run() {
 func (uCli *UserClient) printChainAction() func(c *cli.Context) error {
 	return func(c *cli.Context) error {
 		fmt.Println("Print chain...")
+		if c.IsSet("from") || c.IsSet("to") {
+			to := int64(math.MaxInt64)
+			if c.IsSet("to") {
+				to = c.Int64("to")
+			}
+			fbi, err := uCli.BC.ForwardBlocksIterator(c.Int64("from"), to)
+			if err != nil {
+				return err
+			}
+			for fbi.HasNext() {
+				block, err := fbi.Next()
+				if err != nil {
+					return err
+				}
+				fmt.Println(block.Output())
+			}
+			return nil
+		}
 		bci := uCli.BC.BlocksIterator()
 		for {
 			block, err := bci.Next()
@@ -130,7 +454,7 @@ func (uCli *UserClient) printChainAction() func(c *cli.Context) error {
 				return err
 			}
 			fmt.Println(block.Output())
-			if block.PrevBlockHash == core.NewGenesisBlock().Hash {
+			if block.Header.Height == 0 {
 				fmt.Println("Genesis Block")
 				break
 			}
@@ -142,18 +466,30 @@ func (uCli *UserClient) printChainAction() func(c *cli.Context) error {
 func (uCli *UserClient) printTxsPoolAction() func(c *cli.Context) error {
 	return func(c *cli.Context) error {
 		fmt.Println("Print Txs-Pool...")
-		fmt.Println(uCli.BC.TxsPoolDB.TxsPool.Output())
+		fmt.Println(uCli.BC.TxsPoolDB.Output())
 		return nil
 	}
 }
 
 func (uCli *UserClient) getBlockAction() func(c *cli.Context) error {
 	return func(c *cli.Context) error {
-		hash, err := common.NewHash(c.String("hash"))
-		if err != nil {
-			return fmt.Errorf("illegal hash error: %v", err)
+		var block *core.Block
+		var err error
+		switch {
+		case c.IsSet("hash") && c.IsSet("height"):
+			return fmt.Errorf("getBlock error: only one of --hash and --height can be set")
+		case c.IsSet("height"):
+			block, err = uCli.BC.BlocksDB.GetBlockByHeight(c.Int64("height"))
+		case c.IsSet("hash"):
+			var hash common.Hash
+			hash, err = common.NewHash(c.String("hash"))
+			if err != nil {
+				return fmt.Errorf("illegal hash error: %v", err)
+			}
+			block, err = uCli.BC.BlocksDB.GetBlock(hash)
+		default:
+			return fmt.Errorf("getBlock error: --hash or --height is required")
 		}
-		block, err := uCli.BC.BlocksDB.GetBlock(hash)
 		if err != nil {
 			return fmt.Errorf("getBlock error: %v", err)
 		}
@@ -179,7 +515,13 @@ func (uCli *UserClient) getTransactionAction() func(c *cli.Context) error {
 
 		tx, err := uCli.BC.TransactionsDB.GetTransaction(hash)
 		if err != nil {
-			return fmt.Errorf("getBlock error: %v", err)
+			dropped, droppedErr := uCli.BC.TxsPoolDB.GetDroppedTx(hash)
+			if droppedErr != nil {
+				return fmt.Errorf("getBlock error: %v", err)
+			}
+			fmt.Printf("This transaction has been dropped from Txs-Pool: %v\n", dropped.Reason)
+			fmt.Println(dropped.Tx.Output())
+			return nil
 		}
 		fmt.Println("This transaction has been packaged.")
 		fmt.Println(tx.Output())
@@ -205,7 +547,25 @@ func (uCli *UserClient) sendTransactionAction() func(c *cli.Context) error {
 		if amount < 0 {
 			return fmt.Errorf("amount should be more than 0")
 		}
-		tx, err := core.NewTransaction(from, to, message, amount)
+		if c.Int64("fee") < 0 {
+			return fmt.Errorf("fee should not be less than 0")
+		}
+		if c.Int64("validuntil") < 0 {
+			return fmt.Errorf("validuntil should not be less than 0")
+		}
+		nonce, err := uCli.BC.GetNextNonce(from)
+		if err != nil {
+			return fmt.Errorf("sendTransaction error: %v", err)
+		}
+		rules, err := uCli.BC.NextRules()
+		if err != nil {
+			return fmt.Errorf("sendTransaction error: %v", err)
+		}
+		tx, err := core.NewTransaction(rules, from, to, message, amount, c.Int64("fee"), c.Int64("validuntil"), nonce)
+		if err != nil {
+			return fmt.Errorf("sendTransaction error: %v", err)
+		}
+		err = uCli.KeyStore.SignTx(tx)
 		if err != nil {
 			return fmt.Errorf("sendTransaction error: %v", err)
 		}
@@ -216,3 +576,349 @@ func (uCli *UserClient) sendTransactionAction() func(c *cli.Context) error {
 		return nil
 	}
 }
+
+func (uCli *UserClient) newAccountAction() func(c *cli.Context) error {
+	return func(c *cli.Context) error {
+		passphrase, err := readNewPassphrase()
+		if err != nil {
+			return fmt.Errorf("newAccount error: %v", err)
+		}
+		addr, err := uCli.KeyStore.NewAccount(passphrase)
+		if err != nil {
+			return fmt.Errorf("newAccount error: %v", err)
+		}
+		fmt.Printf("New account: %v\n", addr.Hex(true))
+		return nil
+	}
+}
+
+func (uCli *UserClient) listAccountsAction() func(c *cli.Context) error {
+	return func(c *cli.Context) error {
+		addrs, err := uCli.KeyStore.Accounts()
+		if err != nil {
+			return fmt.Errorf("listAccounts error: %v", err)
+		}
+		fmt.Printf("%v accounts in keystore:\n", len(addrs))
+		for _, addr := range addrs {
+			status := "locked"
+			if uCli.KeyStore.IsUnlocked(addr) {
+				status = "unlocked"
+			}
+			fmt.Printf("  %v (%v)\n", addr.Hex(true), status)
+		}
+		return nil
+	}
+}
+
+func (uCli *UserClient) importKeyAction() func(c *cli.Context) error {
+	return func(c *cli.Context) error {
+		keyHex, err := readSecret("Private key (with prefix \"0x\"): ")
+		if err != nil {
+			return fmt.Errorf("importKey error: %v", err)
+		}
+		key, err := common.NewPrivateKey(keyHex)
+		if err != nil {
+			return fmt.Errorf("illegal private key error: %v", err)
+		}
+		passphrase, err := readNewPassphrase()
+		if err != nil {
+			return fmt.Errorf("importKey error: %v", err)
+		}
+		addr, err := uCli.KeyStore.ImportKey(key, passphrase)
+		if err != nil {
+			return fmt.Errorf("importKey error: %v", err)
+		}
+		fmt.Printf("Imported account: %v\n", addr.Hex(true))
+		return nil
+	}
+}
+
+func (uCli *UserClient) exportKeyAction() func(c *cli.Context) error {
+	return func(c *cli.Context) error {
+		addr, err := common.NewAddress(c.String("addr"))
+		if err != nil {
+			return fmt.Errorf("illegal address error: %v", err)
+		}
+		passphrase, err := readSecret("Passphrase: ")
+		if err != nil {
+			return fmt.Errorf("exportKey error: %v", err)
+		}
+		key, err := uCli.KeyStore.ExportKey(addr, passphrase)
+		if err != nil {
+			return fmt.Errorf("exportKey error: %v", err)
+		}
+		fmt.Printf("Private key: %v\n", common.PrivateKeyHex(key))
+		fmt.Println("Keep the private key safe, anyone who has it can spend your funds.")
+		return nil
+	}
+}
+
+func (uCli *UserClient) unlockAction() func(c *cli.Context) error {
+	return func(c *cli.Context) error {
+		addr, err := common.NewAddress(c.String("addr"))
+		if err != nil {
+			return fmt.Errorf("illegal address error: %v", err)
+		}
+		passphrase, err := readSecret("Passphrase: ")
+		if err != nil {
+			return fmt.Errorf("unlock error: %v", err)
+		}
+		err = uCli.KeyStore.Unlock(addr, passphrase)
+		if err != nil {
+			return fmt.Errorf("unlock error: %v", err)
+		}
+		fmt.Printf("Account %v is unlocked\n", addr.Hex(true))
+		return nil
+	}
+}
+
+func (uCli *UserClient) lockAction() func(c *cli.Context) error {
+	return func(c *cli.Context) error {
+		addr, err := common.NewAddress(c.String("addr"))
+		if err != nil {
+			return fmt.Errorf("illegal address error: %v", err)
+		}
+		err = uCli.KeyStore.Lock(addr)
+		if err != nil {
+			return fmt.Errorf("lock error: %v", err)
+		}
+		fmt.Printf("Account %v is locked\n", addr.Hex(true))
+		return nil
+	}
+}
+
+func (uCli *UserClient) getProofAction() func(c *cli.Context) error {
+	return func(c *cli.Context) error {
+		hash, err := common.NewHash(c.String("hash"))
+		if err != nil {
+			return fmt.Errorf("illegal hash error: %v", err)
+		}
+		proof, err := uCli.BC.GetTxProof(hash)
+		if err != nil {
+			return fmt.Errorf("getProof error: %v", err)
+		}
+		proofJSON, err := json.Marshal(proof)
+		if err != nil {
+			return fmt.Errorf("getProof error: %v", err)
+		}
+		fmt.Println(string(proofJSON))
+		if out := c.String("out"); out != "" {
+			err = ioutil.WriteFile(out, proofJSON, 0644)
+			if err != nil {
+				return fmt.Errorf("getProof error: %v", err)
+			}
+			fmt.Printf("Proof is exported to %v\n", out)
+		}
+		return nil
+	}
+}
+
+func (uCli *UserClient) verifyProofAction() func(c *cli.Context) error {
+	return func(c *cli.Context) error {
+		proofJSON, err := ioutil.ReadFile(c.String("file"))
+		if err != nil {
+			return fmt.Errorf("verifyProof error: %v", err)
+		}
+		var proof core.TxProof
+		err = json.Unmarshal(proofJSON, &proof)
+		if err != nil {
+			return fmt.Errorf("verifyProof error: %v", err)
+		}
+		trustedBlockHash := proof.BlockHash()
+		if c.String("block") != "" {
+			trustedBlockHash, err = common.NewHash(c.String("block"))
+			if err != nil {
+				return fmt.Errorf("illegal block hash error: %v", err)
+			}
+		}
+		err = core.VerifyTxProof(&proof, trustedBlockHash)
+		if err != nil {
+			return fmt.Errorf("verifyProof error: %v", err)
+		}
+		fmt.Println(proof.Output())
+		if c.String("block") == "" {
+			fmt.Printf("✅ Proof is valid if you trust block %v\n", trustedBlockHash.Hex(true))
+		} else {
+			fmt.Println("✅ Proof is valid")
+		}
+		return nil
+	}
+}
+
+func (uCli *UserClient) verifyChainAction() func(c *cli.Context) error {
+	return func(c *cli.Context) error {
+		count, err := uCli.BC.Verify()
+		if err != nil {
+			return fmt.Errorf("verifyChain error: %v", err)
+		}
+		fmt.Printf("✅ Chain is valid: %v blocks verified\n", count)
+		return nil
+	}
+}
+
+func (uCli *UserClient) exportBlockAction() func(c *cli.Context) error {
+	return func(c *cli.Context) error {
+		hash, err := common.NewHash(c.String("hash"))
+		if err != nil {
+			return fmt.Errorf("illegal hash error: %v", err)
+		}
+		block, err := uCli.BC.BlocksDB.GetBlock(hash)
+		if err != nil {
+			return fmt.Errorf("exportBlock error: %v", err)
+		}
+		err = ioutil.WriteFile(c.String("out"), block.Serialize(), 0644)
+		if err != nil {
+			return fmt.Errorf("exportBlock error: %v", err)
+		}
+		fmt.Printf("Block %v is exported to %v\n", hash.Hex(true), c.String("out"))
+		return nil
+	}
+}
+
+func (uCli *UserClient) importBlockAction() func(c *cli.Context) error {
+	return func(c *cli.Context) error {
+		encodedBlock, err := ioutil.ReadFile(c.String("file"))
+		if err != nil {
+			return fmt.Errorf("importBlock error: %v", err)
+		}
+		block, err := core.DeserializeBlock(encodedBlock)
+		if err != nil {
+			return fmt.Errorf("importBlock error: %v", err)
+		}
+		isTip, err := uCli.BC.InsertBlock(block)
+		if err != nil {
+			return fmt.Errorf("importBlock error: %v", err)
+		}
+		if isTip {
+			fmt.Printf("✅ Block %v is imported as the new tip\n", block.Hash.Hex(true))
+		} else {
+			fmt.Printf("✅ Block %v is imported on a side chain\n", block.Hash.Hex(true))
+		}
+		return nil
+	}
+}
+
+func (uCli *UserClient) supplyAction() func(c *cli.Context) error {
+	return func(c *cli.Context) error {
+		supply, err := uCli.BC.GetSupply()
+		if err != nil {
+			return fmt.Errorf("supply error: %v", err)
+		}
+		fmt.Println(supply.Output())
+		return nil
+	}
+}
+
+func (uCli *UserClient) rewindAction() func(c *cli.Context) error {
+	return func(c *cli.Context) error {
+		count, err := uCli.BC.RewindTo(c.Int64("height"))
+		if err != nil {
+			return fmt.Errorf("rewind error: %v", err)
+		}
+		fmt.Printf("⏪ %v blocks are undone, the tip is at height %v now\n", count, c.Int64("height"))
+		return nil
+	}
+}
+
+func (uCli *UserClient) checkpointAction() func(c *cli.Context) error {
+	return func(c *cli.Context) error {
+		checkpoint, err := uCli.BC.GetCheckpoint()
+		if err != nil {
+			return fmt.Errorf("checkpoint error: %v", err)
+		}
+		fmt.Println(checkpoint)
+		return nil
+	}
+}
+
+func (uCli *UserClient) estimateFeeAction() func(c *cli.Context) error {
+	return func(c *cli.Context) error {
+		estimate, err := uCli.BC.EstimateFee(c.Int("blocks"), c.Int64("size"))
+		if err != nil {
+			return fmt.Errorf("estimateFee error: %v", err)
+		}
+		fmt.Println(estimate.Output())
+		return nil
+	}
+}
+
+func (uCli *UserClient) bumpFeeAction() func(c *cli.Context) error {
+	return func(c *cli.Context) error {
+		hash, err := common.NewHash(c.String("hash"))
+		if err != nil {
+			return fmt.Errorf("illegal hash error: %v", err)
+		}
+		tx, err := uCli.BC.NewReplacementTx(hash, c.Int64("fee"))
+		if err != nil {
+			return fmt.Errorf("bumpFee error: %v", err)
+		}
+		err = uCli.KeyStore.SignTx(tx)
+		if err != nil {
+			return fmt.Errorf("bumpFee error: %v", err)
+		}
+		err = uCli.BC.SendTransaction(tx)
+		if err != nil {
+			return fmt.Errorf("bumpFee error: %v", err)
+		}
+		return nil
+	}
+}
+
+func (uCli *UserClient) cancelTxAction() func(c *cli.Context) error {
+	return func(c *cli.Context) error {
+		hash, err := common.NewHash(c.String("hash"))
+		if err != nil {
+			return fmt.Errorf("illegal hash error: %v", err)
+		}
+		tx, err := uCli.BC.NewCancellationTx(hash, c.Int64("fee"))
+		if err != nil {
+			return fmt.Errorf("cancelTx error: %v", err)
+		}
+		err = uCli.KeyStore.SignTx(tx)
+		if err != nil {
+			return fmt.Errorf("cancelTx error: %v", err)
+		}
+		err = uCli.BC.SendTransaction(tx)
+		if err != nil {
+			return fmt.Errorf("cancelTx error: %v", err)
+		}
+		return nil
+	}
+}
+
+func (uCli *UserClient) sendManyAction() func(c *cli.Context) error {
+	return func(c *cli.Context) error {
+		from, err := common.NewAddress(c.String("from"))
+		if err != nil {
+			return fmt.Errorf("illegal from address error: %v", err)
+		}
+		if c.Int64("fee") < 0 || c.Int64("validuntil") < 0 {
+			return fmt.Errorf("fee and validuntil should not be less than 0")
+		}
+		outputs, err := core.ReadTransferOutputs(c.String("file"))
+		if err != nil {
+			return fmt.Errorf("sendMany error: %v", err)
+		}
+		nonce, err := uCli.BC.GetNextNonce(from)
+		if err != nil {
+			return fmt.Errorf("sendMany error: %v", err)
+		}
+		rules, err := uCli.BC.NextRules()
+		if err != nil {
+			return fmt.Errorf("sendMany error: %v", err)
+		}
+		tx, err := core.NewMultiTransferTransaction(rules, from, outputs, c.Int64("fee"), c.Int64("validuntil"), nonce)
+		if err != nil {
+			return fmt.Errorf("sendMany error: %v", err)
+		}
+		err = uCli.KeyStore.SignTx(tx)
+		if err != nil {
+			return fmt.Errorf("sendMany error: %v", err)
+		}
+		err = uCli.BC.SendTransaction(tx)
+		if err != nil {
+			return fmt.Errorf("sendMany error: %v", err)
+		}
+		return nil
+	}
+}
@@ -3,16 +3,26 @@ package main
 import (
 	"github.com/XiaoYao-0/memory-blockchain/client"
 	"github.com/XiaoYao-0/memory-blockchain/core"
+	"github.com/XiaoYao-0/memory-blockchain/keystore"
 	"log"
 )
 
 func main() {
-	bc, err := core.NewBlockchain()
+	genesis, err := core.LoadGenesis(core.GenesisFile)
+	if err != nil {
+		log.Fatal(err)
+	}
+	bc, err := core.NewBlockchain(genesis)
 	if err != nil {
 		log.Fatal(err)
 	}
 	defer bc.CloseDB()
 
-	cli := client.NewUserClient(bc)
+	ks, err := keystore.NewKeyStore(keystore.KeyStoreDir)
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	cli := client.NewUserClient(bc, ks)
 	cli.Run()
 }
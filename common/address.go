@@ -61,5 +61,6 @@ func DeserializeAddress(d []byte) (Address, error) {
 	if len(d) != 20 {
 		return Address{}, fmt.Errorf("DeserializeAddress error: data should be 20 bytes instead of %v bytes", len(d))
 	}
+	copy(addr[:], d)
 	return addr, nil
 }
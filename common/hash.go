@@ -57,5 +57,6 @@ func DeserializeHash(d []byte) (Hash, error) {
 	if len(d) != 32 {
 		return Hash{}, fmt.Errorf("DeserializeHash error: data should be 32 bytes instead of %v bytes", len(d))
 	}
+	copy(hash[:], d)
 	return hash, nil
 }
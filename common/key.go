@@ -0,0 +1,81 @@
+package common
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"math/big"
+	"strings"
+)
+
+// Curve used by all key pairs of the chain
+func Curve() elliptic.Curve {
+	return elliptic.P256()
+}
+
+func GenerateKey() (*ecdsa.PrivateKey, error) {
+	key, err := ecdsa.GenerateKey(Curve(), rand.Reader)
+	if err != nil {
+		return nil, fmt.Errorf("GenerateKey error: %v", err)
+	}
+	return key, nil
+}
+
+// NewPrivateKey with prefix "0x"
+func NewPrivateKey(hexS string) (*ecdsa.PrivateKey, error) {
+	if !strings.HasPrefix(hexS, "0x") {
+		return nil, errors.New("private key hex should start with '0x'")
+	}
+	bytes, err := hex.DecodeString(hexS[2:])
+	if err != nil || len(bytes) != 32 {
+		return nil, errors.New("private key should be a 32-byte hex number")
+	}
+	return PrivateKeyFromBytes(bytes)
+}
+
+func PrivateKeyFromBytes(d []byte) (*ecdsa.PrivateKey, error) {
+	curve := Curve()
+	k := new(big.Int).SetBytes(d)
+	if k.Sign() == 0 || k.Cmp(curve.Params().N) >= 0 {
+		return nil, errors.New("invalid private key")
+	}
+	key := &ecdsa.PrivateKey{D: k}
+	key.PublicKey.Curve = curve
+	key.PublicKey.X, key.PublicKey.Y = curve.ScalarBaseMult(d)
+	return key, nil
+}
+
+// PrivateKeyBytes returns the 32-byte big-endian scalar of the key
+func PrivateKeyBytes(key *ecdsa.PrivateKey) []byte {
+	bytes := make([]byte, 32)
+	return key.D.FillBytes(bytes)
+}
+
+func PrivateKeyHex(key *ecdsa.PrivateKey) string {
+	return fmt.Sprintf("0x%x", PrivateKeyBytes(key))
+}
+
+// PublicKeyBytes returns the uncompressed encoding of the key
+func PublicKeyBytes(pub *ecdsa.PublicKey) []byte {
+	return elliptic.Marshal(Curve(), pub.X, pub.Y)
+}
+
+func PublicKeyFromBytes(d []byte) (*ecdsa.PublicKey, error) {
+	x, y := elliptic.Unmarshal(Curve(), d)
+	if x == nil {
+		return nil, errors.New("invalid public key")
+	}
+	return &ecdsa.PublicKey{Curve: Curve(), X: x, Y: y}, nil
+}
+
+// PubKeyToAddress Address = last 20 bytes of SHA256(PublicKeyBytes)
+func PubKeyToAddress(pub *ecdsa.PublicKey) Address {
+	hash := sha256.Sum256(PublicKeyBytes(pub))
+	var addr Address
+	copy(addr[:], hash[12:])
+	return addr
+}
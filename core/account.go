@@ -12,6 +12,7 @@ type Account struct {
 	Address  common.Address
 	Balance  int64
 	Messages [][]byte
+	Nonce    uint64 // number of transactions sent from the account, also the nonce of the next one
 }
 
 func NewAccount(addr common.Address, balance int64) *Account {
@@ -22,6 +23,30 @@ func NewAccount(addr common.Address, balance int64) *Account {
 	}
 }
 
+func (account *Account) Copy() *Account {
+	messages := make([][]byte, len(account.Messages))
+	copy(messages, account.Messages)
+	return &Account{
+		Address:  account.Address,
+		Balance:  account.Balance,
+		Messages: messages,
+		Nonce:    account.Nonce,
+	}
+}
+
+func (account *Account) Equal(other *Account) bool {
+	if account.Address != other.Address || account.Balance != other.Balance || account.Nonce != other.Nonce ||
+		len(account.Messages) != len(other.Messages) {
+		return false
+	}
+	for i := range account.Messages {
+		if !bytes.Equal(account.Messages[i], other.Messages[i]) {
+			return false
+		}
+	}
+	return true
+}
+
 func (account *Account) Output() string {
 	msgs := make([]string, len(account.Messages))
 	for i := 0; i < len(msgs); i++ {
@@ -31,10 +56,12 @@ func (account *Account) Output() string {
 	return fmt.Sprintf("Account %v\n"+
 		"  Address: %v\n"+
 		"  Balance: %v\n"+
+		"  Nonce: %v (next expected)\n"+
 		"  Messages: %v\n",
 		account.Address.Hex(true),
 		account.Address.Hex(true),
 		account.Balance,
+		account.Nonce,
 		msgsOutput)
 }
 
@@ -4,7 +4,6 @@ import (
 	"fmt"
 	"github.com/XiaoYao-0/memory-blockchain/common"
 	"github.com/boltdb/bolt"
-	"math"
 )
 
 type AccountsDB struct {
@@ -12,16 +11,12 @@ type AccountsDB struct {
 }
 
 const (
-	AccountsFile   = "./data/accounts.db"
 	AccountsBucket = "accounts_bucket"
+	UndoBucket     = "undo_bucket" // hash of block -> BlockUndo of the block on the main chain
 )
 
-func NewAccountsDB() (*AccountsDB, error) {
-	db, err := bolt.Open(AccountsFile, 0666, nil)
-	if err != nil {
-		return nil, fmt.Errorf("NewAccountsDB error: %v", err)
-	}
-	err = db.Update(func(tx *bolt.Tx) error {
+func NewAccountsDB(db *bolt.DB, genesis *Genesis) (*AccountsDB, error) {
+	err := db.Update(func(tx *bolt.Tx) error {
 		b := tx.Bucket([]byte(AccountsBucket))
 
 		if b == nil {
@@ -30,14 +25,15 @@ func NewAccountsDB() (*AccountsDB, error) {
 			if txError != nil {
 				return txError
 			}
-			for _, account := range initAccounts() {
+			for _, account := range initAccounts(genesis) {
 				txError = b.Put(account.Address.Serialize(), account.Serialize())
 				if txError != nil {
 					return txError
 				}
 			}
 		}
-		return nil
+		_, txError := tx.CreateBucketIfNotExists([]byte(UndoBucket))
+		return txError
 	})
 	if err != nil {
 		return nil, fmt.Errorf("NewAccountsDB error: %v", err)
@@ -47,27 +43,10 @@ func NewAccountsDB() (*AccountsDB, error) {
 	}, nil
 }
 
-func (db *AccountsDB) Close() {
-	_ = db.DB.Close()
-}
-
-var InitialAccounts map[common.Address]int64
-
 // InitAccounts allocate initial funds
-func initAccounts() []*Account {
+func initAccounts(genesis *Genesis) []*Account {
 	var accounts []*Account
-	InitialAccounts = make(map[common.Address]int64)
-	addr1, _ := common.NewAddress("0x0000000000000000000000000000000000000001")
-	addr2, _ := common.NewAddress("0x0000000000000000000000000000000000000002")
-	addr3, _ := common.NewAddress("0x0000000000000000000000000000000000000003")
-	addr4, _ := common.NewAddress("0x0000000000000000000000000000000000000004")
-	addr5, _ := common.NewAddress("0x0000000000000000000000000000000000000005")
-	InitialAccounts[addr1] = int64(math.Pow10(10))
-	InitialAccounts[addr2] = int64(math.Pow10(10))
-	InitialAccounts[addr3] = int64(math.Pow10(10))
-	InitialAccounts[addr4] = int64(math.Pow10(10))
-	InitialAccounts[addr5] = int64(math.Pow10(10))
-	for addr, balance := range InitialAccounts {
+	for addr, balance := range genesis.Alloc {
 		if balance < 0 {
 			balance = 0
 		}
@@ -76,167 +55,95 @@ func initAccounts() []*Account {
 	return accounts
 }
 
-func (db *AccountsDB) GetAccountOf(addr common.Address) (*Account, error) {
-	var err error
-	var account *Account
-	account = &Account{
-		Address:  addr,
-		Balance:  0,
-		Messages: [][]byte{},
-	}
-	err = db.DB.Update(func(tx *bolt.Tx) error {
-		b := tx.Bucket([]byte(AccountsBucket))
-
-		if b == nil {
-			return fmt.Errorf("bucket %v do not exist", AccountsBucket)
-		}
-		var txError error
-		encodedAccount := b.Get(addr.Bytes())
-		if encodedAccount == nil {
-			txError = b.Put(addr.Serialize(), account.Serialize())
-			if txError != nil {
-				return txError
-			}
-		} else {
-			account, txError = DeserializeAccount(encodedAccount)
-			if txError != nil {
-				return txError
-			}
-		}
-		return nil
-	})
-	if err != nil {
-		return nil, fmt.Errorf("GetAccountOf error: %v", err)
+// State returns the accounts in a bolt transaction, changes are written only if tx is writable and committed
+func (db *AccountsDB) State(tx *bolt.Tx) (State, error) {
+	b := tx.Bucket([]byte(AccountsBucket))
+	if b == nil {
+		return nil, fmt.Errorf("bucket %v do not exist", AccountsBucket)
 	}
-	return account, nil
+	return &bucketState{bucket: b}, nil
 }
 
-func (db *AccountsDB) GetBalanceOf(addr common.Address) (int64, error) {
-	account, err := db.GetAccountOf(addr)
-	if err != nil {
-		return 0, fmt.Errorf("GetBalanceOf error: %v", err)
+// ApplyBlock execute block under rules on the accounts in tx and record its undo journal
+func (db *AccountsDB) ApplyBlock(tx *bolt.Tx, block *Block, rules *Rules, engine ConsensusEngine) error {
+	b := tx.Bucket([]byte(AccountsBucket))
+	ub := tx.Bucket([]byte(UndoBucket))
+	if b == nil || ub == nil {
+		return fmt.Errorf("ApplyBlock error: bucket %v or %v do not exist", AccountsBucket, UndoBucket)
 	}
-	return account.Balance, nil
-}
-
-func (db *AccountsDB) IncreaseBalanceOf(addr common.Address, amount int64) error {
-	if amount < 0 {
-		return fmt.Errorf("IncreaseBalanceOf error: amount=%v<0", amount)
-	}
-	account, err := db.GetAccountOf(addr)
+	state := NewCachedState(&bucketState{bucket: b})
+	err := block.Exec(state, rules, engine)
 	if err != nil {
-		return fmt.Errorf("IncreaseBalanceOf error: %v", err)
+		return fmt.Errorf("ApplyBlock error: %v", err)
 	}
-	if account.Balance+amount < account.Balance {
-		return fmt.Errorf("IncreaseBalanceOf error: integer overflow: %v+%v->%v", account.Balance, amount, account.Balance+amount)
+	undo := newBlockUndo(b, block.Hash, state)
+	err = ub.Put(block.Hash.Serialize(), undo.Serialize())
+	if err != nil {
+		return fmt.Errorf("ApplyBlock error: %v", err)
 	}
-	account.Balance += amount
-	err = db.DB.Update(func(tx *bolt.Tx) error {
-		b := tx.Bucket([]byte(AccountsBucket))
-
-		if b == nil {
-			return fmt.Errorf("bucket %v do not exist", AccountsBucket)
-		}
-		var txError error
-		txError = b.Put(addr.Serialize(), account.Serialize())
-		if txError != nil {
-			return txError
-		}
-		return nil
-	})
+	err = state.Commit()
 	if err != nil {
-		return fmt.Errorf("IncreaseBalanceOf error: %v", err)
+		return fmt.Errorf("ApplyBlock error: %v", err)
 	}
 	return nil
 }
 
-func (db *AccountsDB) DecreaseBalanceOf(addr common.Address, amount int64) error {
-	if amount < 0 {
-		return fmt.Errorf("DecreaseBalanceOf error: amount=%v<0", amount)
-	}
-	account, err := db.GetAccountOf(addr)
-	if err != nil {
-		return fmt.Errorf("DecreaseBalanceOf error: %v", err)
+// RevertBlock restore the accounts in tx to the values before block blockHash was applied,
+// blockHash should be the last applied block
+func (db *AccountsDB) RevertBlock(tx *bolt.Tx, blockHash common.Hash) error {
+	b := tx.Bucket([]byte(AccountsBucket))
+	ub := tx.Bucket([]byte(UndoBucket))
+	if b == nil || ub == nil {
+		return fmt.Errorf("RevertBlock error: bucket %v or %v do not exist", AccountsBucket, UndoBucket)
 	}
-	if account.Balance-amount > account.Balance {
-		return fmt.Errorf("DecreaseBalanceOf error: integer underflow: %v-%v->%v", account.Balance, amount, account.Balance+amount)
+	encodedUndo := ub.Get(blockHash.Serialize())
+	if encodedUndo == nil {
+		return fmt.Errorf("RevertBlock error: undo journal of block %v do not exist", blockHash.Hex(true))
 	}
-	account.Balance -= amount
-	err = db.DB.Update(func(tx *bolt.Tx) error {
-		b := tx.Bucket([]byte(AccountsBucket))
-		if b == nil {
-			return fmt.Errorf("bucket %v do not exist", AccountsBucket)
-		}
-		var txError error
-		txError = b.Put(addr.Serialize(), account.Serialize())
-		if txError != nil {
-			return txError
-		}
-		return nil
-	})
+	undo, err := DeserializeBlockUndo(encodedUndo)
 	if err != nil {
-		return fmt.Errorf("DecreaseBalanceOf error: %v", err)
+		return fmt.Errorf("RevertBlock error: %v", err)
 	}
-	return nil
-}
-
-func (db *AccountsDB) GetMessagesOf(addr common.Address) ([][]byte, error) {
-	account, err := db.GetAccountOf(addr)
+	err = undo.apply(b)
 	if err != nil {
-		return [][]byte{}, fmt.Errorf("GetMessagesOf error: %v", err)
+		return fmt.Errorf("RevertBlock error: %v", err)
 	}
-	return account.Messages, nil
-}
-
-func (db *AccountsDB) PutMessagesTo(addr common.Address, message []byte) error {
-	account, err := db.GetAccountOf(addr)
+	err = ub.Delete(blockHash.Serialize())
 	if err != nil {
-		return fmt.Errorf("PutMessagesTo error: %v", err)
+		return fmt.Errorf("RevertBlock error: %v", err)
 	}
-	account.Messages = append(account.Messages, message)
-	err = db.DB.Update(func(tx *bolt.Tx) error {
-		b := tx.Bucket([]byte(AccountsBucket))
+	return nil
+}
 
-		if b == nil {
-			return fmt.Errorf("bucket %v do not exist", AccountsBucket)
-		}
-		var txError error
-		txError = b.Put(addr.Serialize(), account.Serialize())
+// GetAccountOf an empty account is returned if addr has never been used
+func (db *AccountsDB) GetAccountOf(addr common.Address) (*Account, error) {
+	var account *Account
+	err := db.DB.View(func(tx *bolt.Tx) error {
+		state, txError := db.State(tx)
 		if txError != nil {
 			return txError
 		}
-		return nil
+		account, txError = state.GetAccount(addr)
+		return txError
 	})
 	if err != nil {
-		return fmt.Errorf("PutMessagesTo error: %v", err)
+		return nil, fmt.Errorf("GetAccountOf error: %v", err)
 	}
-	return nil
+	return account, nil
 }
 
-func (db *AccountsDB) DeleteMessageOf(addr common.Address) error {
+func (db *AccountsDB) GetBalanceOf(addr common.Address) (int64, error) {
 	account, err := db.GetAccountOf(addr)
 	if err != nil {
-		return fmt.Errorf("DeleteMessageOf error: %v", err)
-	}
-	if len(account.Messages) == 0 {
-		return fmt.Errorf("DeleteMessageOf error: account has no message")
+		return 0, fmt.Errorf("GetBalanceOf error: %v", err)
 	}
-	account.Messages = account.Messages[:len(account.Messages)-1]
-	err = db.DB.Update(func(tx *bolt.Tx) error {
-		b := tx.Bucket([]byte(AccountsBucket))
+	return account.Balance, nil
+}
 
-		if b == nil {
-			return fmt.Errorf("bucket %v do not exist", AccountsBucket)
-		}
-		var txError error
-		txError = b.Put(addr.Serialize(), account.Serialize())
-		if txError != nil {
-			return txError
-		}
-		return nil
-	})
+func (db *AccountsDB) GetMessagesOf(addr common.Address) ([][]byte, error) {
+	account, err := db.GetAccountOf(addr)
 	if err != nil {
-		return fmt.Errorf("DeleteMessageOf error: %v", err)
+		return [][]byte{}, fmt.Errorf("GetMessagesOf error: %v", err)
 	}
-	return nil
+	return account.Messages, nil
 }
@@ -2,90 +2,188 @@ package core
 
 import (
 	"bytes"
+	"context"
 	"encoding/gob"
 	"fmt"
 	"github.com/XiaoYao-0/memory-blockchain/common"
+	"github.com/boltdb/bolt"
 	"strings"
 	"time"
 )
 
 const (
-	MaxRetryOfAddingBlock       = 5
-	MinerAwardForOneBlock int64 = 10
+	MinerAwardForOneBlock int64 = 10 // default initial subsidy
 )
 
 type Block struct {
-	Timestamp     int64          // time when block was created
-	Txs           []*Transaction // data of block
-	PrevBlockHash common.Hash    // hash of previous block
-	// Hash = SHA256(PrevBlockHash + Timestamp + Data)
-	Hash  common.Hash // hash of block
-	Nonce int64
-	Miner common.Address
+	Header BlockHeader
+	Hash   common.Hash    // hash of block, Hash = Header.Hash()
+	Txs    []*Transaction // data of block
 }
 
-// NewBlock create a block which transactions are not packaged and proof-of-work not completed
-func NewBlock(txs []*Transaction, prevBlockHash common.Hash) *Block {
+// NewBlock create a block on top of prev which transactions are not packaged and proof-of-work not completed
+func NewBlock(txs []*Transaction, prev *BlockHeader) *Block {
 	block := &Block{
-		Timestamp:     time.Now().Unix(),
-		Txs:           txs,
-		PrevBlockHash: prevBlockHash,
-		Hash:          common.Hash{},
-		Nonce:         0,
+		Header: BlockHeader{
+			Version:       BlockVersion,
+			Height:        prev.Height + 1,
+			Timestamp:     time.Now().Unix(),
+			PrevBlockHash: prev.Hash(),
+			Nonce:         0,
+		},
+		Hash: common.Hash{},
+		Txs:  txs,
 	}
 	return block
 }
 
+// NewGenesisBlock the genesis block is the same on every node and is not mined
 func NewGenesisBlock() *Block {
-	return NewBlock([]*Transaction{}, common.Hash{})
+	block := &Block{
+		Header: BlockHeader{
+			Version:    BlockVersion,
+			Height:     0,
+			Timestamp:  GenesisTimestamp,
+			MerkleRoot: MerkleRoot([]common.Hash{}),
+		},
+		Txs: []*Transaction{},
+	}
+	block.Hash = block.Header.Hash()
+	return block
 }
 
-// BePackaged it will be rolled back if failed
-func (b *Block) BePackaged(miner common.Address, blocksDB *BlocksDB, accountsDB *AccountsDB) ([]*Transaction, error) {
-	var realTxs []*Transaction
-	var notPackagedTxs []*Transaction
-	var err error
+// TxsHashes hashes of txs in order
+func (b *Block) TxsHashes() []common.Hash {
+	hashes := make([]common.Hash, len(b.Txs))
+	for i, tx := range b.Txs {
+		hashes[i] = tx.Hash
+	}
+	return hashes
+}
+
+// Weight the serialized size and the message bytes of all txs except the coinbase one
+func (b *Block) Weight() (int64, int64) {
+	var size, dataSize int64
 	for _, tx := range b.Txs {
-		err = tx.Exec(accountsDB)
-		if err != nil {
-			notPackagedTxs = append(notPackagedTxs, tx)
+		if tx.IsCoinbase() {
 			continue
 		}
-		realTxs = append(realTxs, tx)
+		size += tx.Size()
+		dataSize += int64(len(tx.Data()))
+	}
+	return size, dataSize
+}
+
+// CheckWeight reject a block exceeding the limits of config
+func (b *Block) CheckWeight(config *ChainConfig) error {
+	size, dataSize := b.Weight()
+	if size > config.MaxBlockBytes {
+		return fmt.Errorf("CheckWeight error: txs take %v bytes, more than the limit %v", size, config.MaxBlockBytes)
+	}
+	if dataSize > config.MaxBlockDataBytes {
+		return fmt.Errorf("CheckWeight error: messages take %v bytes, more than the limit %v", dataSize, config.MaxBlockDataBytes)
+	}
+	return nil
+}
+
+// BePackaged select the txs executable on the current accounts and seal the block with engine,
+// nothing is written, the block should be committed by Blockchain.InsertBlock.
+// ErrMiningAborted is returned if ctx is done before the block is sealed
+func (b *Block) BePackaged(ctx context.Context, engine ConsensusEngine, rules *Rules, miner common.Address, accountsDB *AccountsDB) ([]*Transaction, error) {
+	var realTxs []*Transaction
+	var notPackagedTxs []*Transaction
+	// dry run on a read-only view to find out which txs can be executed
+	err := accountsDB.DB.View(func(tx *bolt.Tx) error {
+		base, txError := accountsDB.State(tx)
+		if txError != nil {
+			return txError
+		}
+		state := NewCachedState(base)
+		for _, transaction := range b.Txs {
+			// forged transactions are dropped instead of being returned to the pool
+			if transaction.Verify() != nil {
+				continue
+			}
+			if transaction.ExpiredAt(b.Header.Height, b.Header.Timestamp) {
+				notPackagedTxs = append(notPackagedTxs, transaction)
+				continue
+			}
+			txState := NewCachedState(state)
+			if transaction.Exec(txState, rules) != nil {
+				notPackagedTxs = append(notPackagedTxs, transaction)
+				continue
+			}
+			txError = txState.Commit()
+			if txError != nil {
+				return txError
+			}
+			realTxs = append(realTxs, transaction)
+		}
+		return nil
+	})
+	if err != nil {
+		return []*Transaction{}, fmt.Errorf("BePackaged error: %v", err)
 	}
 	if len(realTxs) == 0 {
 		return []*Transaction{}, fmt.Errorf("BePackaged error: No transaction executed successfully")
 	}
 	oldB := *b
 	b.Txs = realTxs
-	pow := NewProofOfWork(b)
-	nonce, hash := pow.Run()
-	b.Nonce = nonce
-	b.Hash = hash
-	b.Miner = miner
-	// award to miner
-	award := MinerAwardForOneBlock
-	for _, tx := range b.Txs {
-		award += tx.Fee
+	b.Header.Miner = miner
+	coinbase := NewCoinbaseTransaction(&b.Header, engine.CalculateReward(&b.Header, b.Fees()))
+	b.Txs = append([]*Transaction{coinbase}, realTxs...)
+	b.Header.MerkleRoot = MerkleRoot(b.TxsHashes())
+	err = engine.Seal(ctx, &b.Header)
+	if err == ErrMiningAborted {
+		b.Header, b.Txs = oldB.Header, oldB.Txs
+		return []*Transaction{}, err
 	}
-	err = accountsDB.IncreaseBalanceOf(b.Miner, award)
 	if err != nil {
-		for _, tx := range realTxs {
-			tx.RollBack(accountsDB)
+		b.Header, b.Txs = oldB.Header, oldB.Txs
+		return []*Transaction{}, fmt.Errorf("BePackaged error: %v", err)
+	}
+	b.Hash = b.Header.Hash()
+	return notPackagedTxs, nil
+}
+
+// Exec verify and execute the coinbase transaction and all other txs under rules of the block,
+// state may be partially changed if it fails
+func (b *Block) Exec(state State, rules *Rules, engine ConsensusEngine) error {
+	if len(b.Txs) == 0 || !b.Txs[0].IsCoinbase() {
+		return fmt.Errorf("Exec error: the first transaction should be a coinbase one")
+	}
+	for _, tx := range b.Txs[1:] {
+		err := tx.Verify()
+		if err != nil {
+			return fmt.Errorf("Exec error: %v", err)
+		}
+		if tx.ExpiredAt(b.Header.Height, b.Header.Timestamp) {
+			return fmt.Errorf("Exec error: transaction %v %v", tx.Hash.Hex(true), tx.expiredReason(b.Header.Height, b.Header.Timestamp))
+		}
+		err = tx.Exec(state, rules)
+		if err != nil {
+			return fmt.Errorf("Exec error: transaction %v: %v", tx.Hash.Hex(true), err)
 		}
-		b.Txs, b.Nonce, b.Hash, b.Miner = oldB.Txs, oldB.Nonce, oldB.Hash, oldB.Miner
-		return []*Transaction{}, fmt.Errorf("BePackaged error: package failed and all txs are rolled back")
 	}
-	// add block
-	err = blocksDB.AddBlockWithRetry(b, MaxRetryOfAddingBlock)
+	coinbase := b.Txs[0]
+	err := verifyCoinbase(coinbase, &b.Header, engine.CalculateReward(&b.Header, b.Fees()))
 	if err != nil {
-		for _, tx := range realTxs {
-			tx.RollBack(accountsDB)
-		}
-		b.Txs, b.Nonce, b.Hash, b.Miner = oldB.Txs, oldB.Nonce, oldB.Hash, oldB.Miner
-		return []*Transaction{}, fmt.Errorf("BePackaged error: package failed and all txs are rolled back")
+		return fmt.Errorf("Exec error: %v", err)
 	}
-	return notPackagedTxs, nil
+	err = execCoinbase(coinbase, state)
+	if err != nil {
+		return fmt.Errorf("Exec error: %v", err)
+	}
+	return nil
+}
+
+// Fees the sum of fees of all txs
+func (b *Block) Fees() int64 {
+	var fees int64
+	for _, tx := range b.Txs {
+		fees += tx.Fee
+	}
+	return fees
 }
 
 func (b *Block) Output() string {
@@ -94,19 +192,31 @@ func (b *Block) Output() string {
 		txsHash[i] = tx.Hash.Hex(true)
 	}
 	txsOutput := strings.Join(txsHash, "\n      ")
+	size, dataSize := b.Weight()
 	return fmt.Sprintf("Block %v\n"+
+		"  Version: %v\n"+
+		"  Height: %v\n"+
 		"  Timestamp: %v\n"+
 		"  PrevBlockHash: %v\n"+
+		"  MerkleRoot: %v\n"+
 		"  Hash: %v\n"+
+		"  Bits: %v\n"+
 		"  Nonce: %v\n"+
 		"  Miner: %v\n"+
+		"  Size: %v bytes, %v message bytes\n"+
 		"  Txs: %v\n",
 		b.Hash.Hex(true),
-		time.Unix(b.Timestamp, 0).Format(time.RFC3339),
-		b.PrevBlockHash.Hex(true),
+		b.Header.Version,
+		b.Header.Height,
+		time.Unix(b.Header.Timestamp, 0).Format(time.RFC3339),
+		b.Header.PrevBlockHash.Hex(true),
+		b.Header.MerkleRoot.Hex(true),
 		b.Hash.Hex(true),
-		b.Nonce,
-		b.Miner.Hex(true),
+		b.Header.Bits,
+		b.Header.Nonce,
+		b.Header.Miner.Hex(true),
+		size,
+		dataSize,
 		txsOutput)
 }
 
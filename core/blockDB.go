@@ -1,28 +1,36 @@
 package core
 
 import (
+	"encoding/binary"
+	"errors"
 	"fmt"
 	"github.com/XiaoYao-0/memory-blockchain/common"
 	"github.com/boltdb/bolt"
+	"math/big"
+	"time"
 )
 
 type BlocksDB struct {
-	DB *bolt.DB
+	DB     *bolt.DB
+	Config *ChainConfig
+	Engine ConsensusEngine
+	Clock  Clock // time.Now by default
 }
 
 const (
-	BlocksDBFile  = "./data/blocks.db"
-	BlocksBucket  = "blocks_bucket"
-	LastBlockHash = "last_block_hash"
+	BlocksBucket    = "blocks_bucket"
+	HeadersBucket   = "headers_bucket"    // headers are also stored alone to be read without bodies
+	TotalWorkBucket = "total_work_bucket" // hash of block -> total work of the chain ending with the block
+	HeightBucket    = "height_bucket"     // height -> hash of the block at the height on the main chain
+	// hash of a block undone by RewindTo or descending from one -> empty, no block can extend it
+	InvalidBlocksBucket = "invalid_blocks_bucket"
+	LastBlockHash       = "last_block_hash"
 )
 
-func NewBlocksDB() (*BlocksDB, error) {
-	db, err := bolt.Open(BlocksDBFile, 0666, nil)
-	if err != nil {
-		return nil, fmt.Errorf("NewBlocksDB error: %v", err)
-	}
+var ErrKnownBlock = errors.New("block is already known")
 
-	err = db.Update(func(tx *bolt.Tx) error {
+func NewBlocksDB(db *bolt.DB, config *ChainConfig, engine ConsensusEngine) (*BlocksDB, error) {
+	err := db.Update(func(tx *bolt.Tx) error {
 		b := tx.Bucket([]byte(BlocksBucket))
 
 		if b == nil {
@@ -32,29 +40,61 @@ func NewBlocksDB() (*BlocksDB, error) {
 			if txError != nil {
 				return txError
 			}
+			hb, txError := tx.CreateBucket([]byte(HeadersBucket))
+			if txError != nil {
+				return txError
+			}
 			txError = b.Put(genesis.Hash.Bytes(), genesis.Serialize())
 			if txError != nil {
 				return txError
 			}
+			txError = hb.Put(genesis.Hash.Bytes(), genesis.Header.Serialize())
+			if txError != nil {
+				return txError
+			}
+			wb, txError := tx.CreateBucket([]byte(TotalWorkBucket))
+			if txError != nil {
+				return txError
+			}
+			txError = wb.Put(genesis.Hash.Bytes(), blockWork(&genesis.Header).Bytes())
+			if txError != nil {
+				return txError
+			}
+			ib, txError := tx.CreateBucket([]byte(HeightBucket))
+			if txError != nil {
+				return txError
+			}
+			txError = ib.Put(heightKey(genesis.Header.Height), genesis.Hash.Bytes())
+			if txError != nil {
+				return txError
+			}
+			sb, txError := tx.CreateBucket([]byte(SupplyBucket))
+			if txError != nil {
+				return txError
+			}
+			txError = sb.Put(genesis.Hash.Bytes(), (&Supply{}).Serialize())
+			if txError != nil {
+				return txError
+			}
 			txError = b.Put([]byte(LastBlockHash), genesis.Hash.Bytes())
 			if txError != nil {
 				return txError
 			}
 		}
-		return nil
+		_, txError := tx.CreateBucketIfNotExists([]byte(InvalidBlocksBucket))
+		return txError
 	})
 	if err != nil {
 		return nil, fmt.Errorf("NewBlocksDB error: %v", err)
 	}
 	return &BlocksDB{
-		DB: db,
+		DB:     db,
+		Config: config,
+		Engine: engine,
+		Clock:  time.Now,
 	}, nil
 }
 
-func (db *BlocksDB) Close() {
-	_ = db.DB.Close()
-}
-
 func (db *BlocksDB) GetLastBlockHash() (common.Hash, error) {
 	var hash common.Hash
 	err := db.DB.View(func(tx *bolt.Tx) error {
@@ -79,21 +119,9 @@ func (db *BlocksDB) GetLastBlockHash() (common.Hash, error) {
 func (db *BlocksDB) GetBlock(hash common.Hash) (*Block, error) {
 	var block *Block
 	err := db.DB.View(func(tx *bolt.Tx) error {
-		b := tx.Bucket([]byte(BlocksBucket))
-
-		if b == nil {
-			return fmt.Errorf("bucket %v do not exist", BlocksBucket)
-		}
 		var txError error
-		encodedBlock := b.Get(hash.Serialize())
-		if encodedBlock == nil {
-			return fmt.Errorf("block %v do not exist", hash.Hex(true))
-		}
-		block, txError = DeserializeBlock(encodedBlock)
-		if txError != nil {
-			return txError
-		}
-		return nil
+		block, txError = getBlock(tx, hash)
+		return txError
 	})
 	if err != nil {
 		return nil, fmt.Errorf("GetBlock error: %v", err)
@@ -101,35 +129,217 @@ func (db *BlocksDB) GetBlock(hash common.Hash) (*Block, error) {
 	return block, nil
 }
 
-func (db *BlocksDB) AddBlock(block *Block) error {
-	var err error
-	err = db.DB.Update(func(tx *bolt.Tx) error {
-		b := tx.Bucket([]byte(BlocksBucket))
+func getBlock(tx *bolt.Tx, hash common.Hash) (*Block, error) {
+	b := tx.Bucket([]byte(BlocksBucket))
+	if b == nil {
+		return nil, fmt.Errorf("bucket %v do not exist", BlocksBucket)
+	}
+	encodedBlock := b.Get(hash.Serialize())
+	if encodedBlock == nil {
+		return nil, fmt.Errorf("block %v do not exist", hash.Hex(true))
+	}
+	return DeserializeBlock(encodedBlock)
+}
 
-		if b == nil {
-			return fmt.Errorf("bucket %v do not exist", BlocksBucket)
-		}
+func (db *BlocksDB) GetHeader(hash common.Hash) (*BlockHeader, error) {
+	var header *BlockHeader
+	err := db.DB.View(func(tx *bolt.Tx) error {
 		var txError error
-		txError = b.Put(block.Hash.Serialize(), block.Serialize())
+		header, txError = getHeader(tx, hash)
+		return txError
+	})
+	if err != nil {
+		return nil, fmt.Errorf("GetHeader error: %v", err)
+	}
+	return header, nil
+}
+
+func getHeader(tx *bolt.Tx, hash common.Hash) (*BlockHeader, error) {
+	b := tx.Bucket([]byte(HeadersBucket))
+	if b == nil {
+		return nil, fmt.Errorf("bucket %v do not exist", HeadersBucket)
+	}
+	encodedHeader := b.Get(hash.Serialize())
+	if encodedHeader == nil {
+		return nil, fmt.Errorf("header %v do not exist", hash.Hex(true))
+	}
+	return DeserializeBlockHeader(encodedHeader)
+}
+
+// GetBlockByHeight returns the block at height on the main chain
+func (db *BlocksDB) GetBlockByHeight(height int64) (*Block, error) {
+	var block *Block
+	err := db.DB.View(func(tx *bolt.Tx) error {
+		hash, txError := getHashByHeight(tx, height)
 		if txError != nil {
 			return txError
 		}
-		return nil
+		block, txError = getBlock(tx, hash)
+		return txError
+	})
+	if err != nil {
+		return nil, fmt.Errorf("GetBlockByHeight error: %v", err)
+	}
+	return block, nil
+}
+
+func getHashByHeight(tx *bolt.Tx, height int64) (common.Hash, error) {
+	b := tx.Bucket([]byte(HeightBucket))
+	if b == nil {
+		return common.Hash{}, fmt.Errorf("bucket %v do not exist", HeightBucket)
+	}
+	encodedHash := b.Get(heightKey(height))
+	if encodedHash == nil {
+		return common.Hash{}, fmt.Errorf("there is no block at height %v on the main chain", height)
+	}
+	return common.DeserializeHash(encodedHash)
+}
+
+// heightKey big-endian, so keys are sorted by height
+func heightKey(height int64) []byte {
+	key := make([]byte, 8)
+	binary.BigEndian.PutUint64(key, uint64(height))
+	return key
+}
+
+// GetTotalWork returns the total work of the chain ending with block hash
+func (db *BlocksDB) GetTotalWork(hash common.Hash) (*big.Int, error) {
+	var work *big.Int
+	err := db.DB.View(func(tx *bolt.Tx) error {
+		var txError error
+		work, txError = getTotalWork(tx, hash)
+		return txError
 	})
+	if err != nil {
+		return nil, fmt.Errorf("GetTotalWork error: %v", err)
+	}
+	return work, nil
+}
+
+func getTotalWork(tx *bolt.Tx, hash common.Hash) (*big.Int, error) {
+	b := tx.Bucket([]byte(TotalWorkBucket))
+	if b == nil {
+		return nil, fmt.Errorf("bucket %v do not exist", TotalWorkBucket)
+	}
+	encodedWork := b.Get(hash.Serialize())
+	if encodedWork == nil {
+		return nil, fmt.Errorf("total work of block %v do not exist", hash.Hex(true))
+	}
+	return new(big.Int).SetBytes(encodedWork), nil
+}
+
+// blockWork the expected number of hashes to seal header, it is 1 for engines without proof-of-work
+func blockWork(header *BlockHeader) *big.Int {
+	if header.Bits <= 0 || header.Bits > 255 {
+		return big.NewInt(1)
+	}
+	return new(big.Int).Lsh(big.NewInt(1), uint(header.Bits))
+}
+
+// getTip returns the hash of the last block of the main chain in tx
+func getTip(tx *bolt.Tx) (common.Hash, error) {
+	b := tx.Bucket([]byte(BlocksBucket))
+	if b == nil {
+		return common.Hash{}, fmt.Errorf("bucket %v do not exist", BlocksBucket)
+	}
+	return common.DeserializeHash(b.Get([]byte(LastBlockHash)))
+}
+
+// AddBlock write block and its total work in tx, the parent of block should be known, it should not conflict
+// with checkpoints, its timestamp should be valid and block should be accepted by the consensus engine.
+// The block may be on a side chain, the tip is not moved
+func (db *BlocksDB) AddBlock(tx *bolt.Tx, block *Block) error {
+	b := tx.Bucket([]byte(BlocksBucket))
+	hb := tx.Bucket([]byte(HeadersBucket))
+	wb := tx.Bucket([]byte(TotalWorkBucket))
+	if b == nil || hb == nil || wb == nil {
+		return fmt.Errorf("AddBlock error: bucket %v, %v or %v do not exist", BlocksBucket, HeadersBucket, TotalWorkBucket)
+	}
+	if block.Hash != block.Header.Hash() {
+		return fmt.Errorf("AddBlock error: hash %v does not match the header", block.Hash.Hex(true))
+	}
+	if b.Get(block.Hash.Serialize()) != nil {
+		return ErrKnownBlock
+	}
+	if isInvalidBlock(tx, block.Header.PrevBlockHash) {
+		return fmt.Errorf("AddBlock error: previous block %v has been rewound", block.Header.PrevBlockHash.Hex(true))
+	}
+	prev, err := getHeader(tx, block.Header.PrevBlockHash)
+	if err != nil {
+		return fmt.Errorf("AddBlock error: unknown previous block: %v", err)
+	}
+	if block.Header.Height != prev.Height+1 {
+		return fmt.Errorf("AddBlock error: height=%v, expected %v", block.Header.Height, prev.Height+1)
+	}
+	err = db.Config.verifyCheckpoint(block.Header.Height, block.Hash)
+	if err != nil {
+		return fmt.Errorf("AddBlock error: %v", err)
+	}
+	err = verifyTimestamp(&txHeaderReader{tx}, db.Config, &block.Header, prev, db.Clock())
+	if err != nil {
+		return fmt.Errorf("AddBlock error: %v", err)
+	}
+	err = db.Engine.VerifyHeader(&txHeaderReader{tx}, &block.Header, prev)
+	if err != nil {
+		return fmt.Errorf("AddBlock error: %v", err)
+	}
+	prevWork, err := getTotalWork(tx, block.Header.PrevBlockHash)
+	if err != nil {
+		return fmt.Errorf("AddBlock error: %v", err)
+	}
+	err = b.Put(block.Hash.Serialize(), block.Serialize())
+	if err != nil {
+		return fmt.Errorf("AddBlock error: %v", err)
+	}
+	err = hb.Put(block.Hash.Serialize(), block.Header.Serialize())
+	if err != nil {
+		return fmt.Errorf("AddBlock error: %v", err)
+	}
+	err = wb.Put(block.Hash.Serialize(), new(big.Int).Add(prevWork, blockWork(&block.Header)).Bytes())
 	if err != nil {
 		return fmt.Errorf("AddBlock error: %v", err)
 	}
 	return nil
 }
 
-func (db *BlocksDB) AddBlockWithRetry(block *Block, maxRetry int) error {
-	var err error
-	for i := 0; i < maxRetry; i++ {
-		err = db.AddBlock(block)
-		if err != nil {
-			continue
-		}
-		break
+// IndexBlock put block into the height index of the main chain in tx
+func (db *BlocksDB) IndexBlock(tx *bolt.Tx, block *Block) error {
+	b := tx.Bucket([]byte(HeightBucket))
+	if b == nil {
+		return fmt.Errorf("IndexBlock error: bucket %v do not exist", HeightBucket)
 	}
-	return err
+	err := b.Put(heightKey(block.Header.Height), block.Hash.Serialize())
+	if err != nil {
+		return fmt.Errorf("IndexBlock error: %v", err)
+	}
+	return nil
+}
+
+// UnindexBlock remove block from the height index in tx when it leaves the main chain
+func (db *BlocksDB) UnindexBlock(tx *bolt.Tx, block *Block) error {
+	b := tx.Bucket([]byte(HeightBucket))
+	if b == nil {
+		return fmt.Errorf("UnindexBlock error: bucket %v do not exist", HeightBucket)
+	}
+	err := b.Delete(heightKey(block.Header.Height))
+	if err != nil {
+		return fmt.Errorf("UnindexBlock error: %v", err)
+	}
+	return nil
+}
+
+// SetTip move the tip to a known block in tx
+func (db *BlocksDB) SetTip(tx *bolt.Tx, hash common.Hash) error {
+	b := tx.Bucket([]byte(BlocksBucket))
+	if b == nil {
+		return fmt.Errorf("SetTip error: bucket %v do not exist", BlocksBucket)
+	}
+	if b.Get(hash.Serialize()) == nil {
+		return fmt.Errorf("SetTip error: block %v do not exist", hash.Hex(true))
+	}
+	err := b.Put([]byte(LastBlockHash), hash.Serialize())
+	if err != nil {
+		return fmt.Errorf("SetTip error: %v", err)
+	}
+	return nil
 }
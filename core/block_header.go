@@ -0,0 +1,108 @@
+package core
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/binary"
+	"encoding/gob"
+	"fmt"
+	"github.com/XiaoYao-0/memory-blockchain/common"
+	"time"
+)
+
+const (
+	BlockVersion     int32 = 1
+	GenesisTimestamp int64 = 1646870400 // 2022-03-10T00:00:00Z
+)
+
+// BlockHeader everything the consensus engine commits to, transactions are committed by MerkleRoot
+type BlockHeader struct {
+	Version       int32
+	Height        int64       // genesis block is at height 0
+	Timestamp     int64       // time when block was created
+	PrevBlockHash common.Hash // hash of previous block
+	MerkleRoot    common.Hash // Merkle root of hashes of txs
+	Bits          int64       // difficulty, number of leading zero bits of the hash
+	Nonce         int64
+	Miner         common.Address
+	Seal          []byte // signature of engines sealing by signing, empty for proof-of-work
+}
+
+// bytesWithNonce fixed-width encoding of the header, nonce takes the place of h.Nonce
+func (h *BlockHeader) bytesWithNonce(nonce int64) []byte {
+	data := h.bytesWithoutSeal(nonce)
+	if len(h.Seal) == 0 {
+		return data
+	}
+	var buf bytes.Buffer
+	buf.Write(data)
+	_ = binary.Write(&buf, binary.BigEndian, int32(len(h.Seal)))
+	buf.Write(h.Seal)
+	return buf.Bytes()
+}
+
+func (h *BlockHeader) bytesWithoutSeal(nonce int64) []byte {
+	var buf bytes.Buffer
+	_ = binary.Write(&buf, binary.BigEndian, h.Version)
+	_ = binary.Write(&buf, binary.BigEndian, h.Height)
+	_ = binary.Write(&buf, binary.BigEndian, h.Timestamp)
+	buf.Write(h.PrevBlockHash.Bytes())
+	buf.Write(h.MerkleRoot.Bytes())
+	_ = binary.Write(&buf, binary.BigEndian, h.Bits)
+	_ = binary.Write(&buf, binary.BigEndian, nonce)
+	buf.Write(h.Miner.Bytes())
+	return buf.Bytes()
+}
+
+// Hash = SHA256(Version + Height + Timestamp + PrevBlockHash + MerkleRoot + Bits + Nonce + Miner [+ len(Seal) + Seal])
+func (h *BlockHeader) Hash() common.Hash {
+	return sha256.Sum256(h.bytesWithNonce(h.Nonce))
+}
+
+// SealHash the hash signed by engines sealing by signing, Seal is not included
+func (h *BlockHeader) SealHash() common.Hash {
+	return sha256.Sum256(h.bytesWithoutSeal(h.Nonce))
+}
+
+func (h *BlockHeader) Output() string {
+	return fmt.Sprintf("BlockHeader %v\n"+
+		"  Version: %v\n"+
+		"  Height: %v\n"+
+		"  Timestamp: %v\n"+
+		"  PrevBlockHash: %v\n"+
+		"  MerkleRoot: %v\n"+
+		"  Bits: %v\n"+
+		"  Nonce: %v\n"+
+		"  Miner: %v\n"+
+		"  Seal: 0x%x\n",
+		h.Hash().Hex(true),
+		h.Version,
+		h.Height,
+		time.Unix(h.Timestamp, 0).Format(time.RFC3339),
+		h.PrevBlockHash.Hex(true),
+		h.MerkleRoot.Hex(true),
+		h.Bits,
+		h.Nonce,
+		h.Miner.Hex(true),
+		h.Seal)
+}
+
+func (h *BlockHeader) Serialize() []byte {
+	var result bytes.Buffer
+	encoder := gob.NewEncoder(&result)
+
+	_ = encoder.Encode(h)
+
+	return result.Bytes()
+}
+
+func DeserializeBlockHeader(d []byte) (*BlockHeader, error) {
+	var header BlockHeader
+
+	decoder := gob.NewDecoder(bytes.NewReader(d))
+	err := decoder.Decode(&header)
+	if err != nil {
+		return nil, fmt.Errorf("DeserializeBlockHeader error: %v", err)
+	}
+	return &header, nil
+}
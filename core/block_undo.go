@@ -0,0 +1,70 @@
+package core
+
+import (
+	"bytes"
+	"encoding/gob"
+	"fmt"
+	"github.com/XiaoYao-0/memory-blockchain/common"
+	"github.com/boltdb/bolt"
+)
+
+// UndoEntry the value of an account before a block was applied
+type UndoEntry struct {
+	Address common.Address
+	Prior   []byte // encoded account, nil if the account did not exist
+}
+
+// BlockUndo journal of a block, applying it in reverse order of blocks restores the accounts bucket
+type BlockUndo struct {
+	BlockHash common.Hash
+	Entries   []UndoEntry
+}
+
+// newBlockUndo record the prior values in bucket of every account changed in state
+func newBlockUndo(bucket *bolt.Bucket, blockHash common.Hash, state *CachedState) *BlockUndo {
+	undo := &BlockUndo{BlockHash: blockHash}
+	for _, addr := range state.Addresses() {
+		var prior []byte
+		if encodedAccount := bucket.Get(addr.Serialize()); encodedAccount != nil {
+			prior = append([]byte{}, encodedAccount...)
+		}
+		undo.Entries = append(undo.Entries, UndoEntry{Address: addr, Prior: prior})
+	}
+	return undo
+}
+
+// apply restore the accounts in bucket
+func (u *BlockUndo) apply(bucket *bolt.Bucket) error {
+	for _, entry := range u.Entries {
+		var err error
+		if entry.Prior == nil {
+			err = bucket.Delete(entry.Address.Serialize())
+		} else {
+			err = bucket.Put(entry.Address.Serialize(), entry.Prior)
+		}
+		if err != nil {
+			return fmt.Errorf("apply error: %v", err)
+		}
+	}
+	return nil
+}
+
+func (u *BlockUndo) Serialize() []byte {
+	var result bytes.Buffer
+	encoder := gob.NewEncoder(&result)
+
+	_ = encoder.Encode(u)
+
+	return result.Bytes()
+}
+
+func DeserializeBlockUndo(d []byte) (*BlockUndo, error) {
+	var undo BlockUndo
+
+	decoder := gob.NewDecoder(bytes.NewReader(d))
+	err := decoder.Decode(&undo)
+	if err != nil {
+		return nil, fmt.Errorf("DeserializeBlockUndo error: %v", err)
+	}
+	return &undo, nil
+}
@@ -1,65 +1,203 @@
 package core
 
 import (
+	"context"
 	"fmt"
 	"github.com/XiaoYao-0/memory-blockchain/common"
+	"github.com/boltdb/bolt"
+	"os"
+	"sync"
+)
+
+const (
+	ChainDBFile = "./data/chain.db"
 )
 
 type Blockchain struct {
-	Tip            common.Hash // the hash of the last block in a chain
+	Genesis        *Genesis
+	Config         *ChainConfig
+	Engine         ConsensusEngine
+	tip            common.Hash // the hash of the last block in a chain, read by CurrentTip and changed by setTip
+	tipMu          sync.Mutex
+	tipChanged     chan struct{} // closed when tip changes
+	chainMu        sync.Mutex    // serializes InsertBlock and RewindTo, so tip follows the order of their bolt transactions
+	DB             *bolt.DB      // blocks, accounts, transactions and Txs-Pool share one bolt file
 	BlocksDB       *BlocksDB
 	AccountsDB     *AccountsDB
 	TransactionsDB *TransactionsDB
 	TxsPoolDB      *TxsPoolDB
 }
 
-func NewBlockchain() (*Blockchain, error) {
-	blocksDB, err := NewBlocksDB()
+func NewBlockchain(genesis *Genesis) (*Blockchain, error) {
+	engine, err := NewConsensusEngine(genesis.Config)
+	if err != nil {
+		return nil, fmt.Errorf("NewBlockchain error: %v", err)
+	}
+	db, err := bolt.Open(ChainDBFile, 0666, nil)
+	if err != nil {
+		return nil, fmt.Errorf("NewBlockchain error: %v", err)
+	}
+	blocksDB, err := NewBlocksDB(db, genesis.Config, engine)
 	if err != nil {
+		_ = db.Close()
 		return nil, fmt.Errorf("NewBlockchain error: %v", err)
 	}
-	accountsDB, err := NewAccountsDB()
+	accountsDB, err := NewAccountsDB(db, genesis)
 	if err != nil {
+		_ = db.Close()
 		return nil, fmt.Errorf("NewBlockchain error: %v", err)
 	}
-	transactionsDB, err := NewTransactionsDB()
+	transactionsDB, err := NewTransactionsDB(db)
 	if err != nil {
+		_ = db.Close()
 		return nil, fmt.Errorf("NewBlockchain error: %v", err)
 	}
-	txsPool, err := NewTxsPoolDB()
+	// transactions of a node from before chain.db stay readable by their hashes
+	if _, err = os.Stat(LegacyTransactionsDBFile); err == nil {
+		imported, err := transactionsDB.ImportLegacyTransactions(LegacyTransactionsDBFile)
+		if err != nil {
+			_ = db.Close()
+			return nil, fmt.Errorf("NewBlockchain error: %v", err)
+		}
+		if imported > 0 {
+			fmt.Printf("📦 %v transactions are imported from %v\n", imported, LegacyTransactionsDBFile)
+		}
+	}
+	txsPool, err := NewTxsPoolDB(db)
 	if err != nil {
+		_ = db.Close()
 		return nil, fmt.Errorf("NewBlockchain error: %v", err)
 	}
 	tip, err := blocksDB.GetLastBlockHash()
 	if err != nil {
+		_ = db.Close()
 		return nil, fmt.Errorf("NewBlockchain error: %v", err)
 	}
 	bc := Blockchain{
-		Tip:            tip,
+		Genesis:        genesis,
+		Config:         genesis.Config,
+		Engine:         engine,
+		tip:            tip,
+		DB:             db,
 		BlocksDB:       blocksDB,
 		AccountsDB:     accountsDB,
 		TransactionsDB: transactionsDB,
 		TxsPoolDB:      txsPool,
+		tipChanged:     make(chan struct{}),
+	}
+	err = bc.verifyHeaders()
+	if err != nil {
+		_ = db.Close()
+		return nil, fmt.Errorf("NewBlockchain error: %v", err)
 	}
 	return &bc, nil
 }
 
+func (bc *Blockchain) setTip(hash common.Hash) {
+	bc.tipMu.Lock()
+	defer bc.tipMu.Unlock()
+	bc.tip = hash
+	close(bc.tipChanged)
+	bc.tipChanged = make(chan struct{})
+}
+
+// CurrentTip the hash of the last block of the main chain, it can be called while blocks are inserted
+func (bc *Blockchain) CurrentTip() common.Hash {
+	bc.tipMu.Lock()
+	defer bc.tipMu.Unlock()
+	return bc.tip
+}
+
+// TipChanged returns a channel which is closed when the tip changes next time
+func (bc *Blockchain) TipChanged() <-chan struct{} {
+	bc.tipMu.Lock()
+	defer bc.tipMu.Unlock()
+	return bc.tipChanged
+}
+
 func (bc *Blockchain) CloseDB() {
-	bc.BlocksDB.Close()
-	bc.AccountsDB.Close()
+	bc.TxsPoolDB.Wait()
+	_ = bc.DB.Close()
+}
+
+// NextRules the rules of the next block on the tip
+func (bc *Blockchain) NextRules() (*Rules, error) {
+	tip, err := bc.BlocksDB.GetHeader(bc.CurrentTip())
+	if err != nil {
+		return nil, fmt.Errorf("NextRules error: %v", err)
+	}
+	return bc.Config.Rules(tip.Height + 1), nil
 }
 
 func (bc *Blockchain) SendTransaction(tx *Transaction) error {
-	// Check if there is enough balance in the account to pay the handling fee and transfer amount
-	balance, err := bc.AccountsDB.GetBalanceOf(tx.From)
+	err := tx.Verify()
 	if err != nil {
-		return fmt.Errorf("SendTransaction error: "+
-			"fail to check if there is enough balance in the account to pay the handling fee and transfer amount: %v", err)
+		return fmt.Errorf("SendTransaction error: %v", err)
 	}
-	if balance < tx.Amount+tx.Fee {
+	rules, err := bc.NextRules()
+	if err != nil {
+		return fmt.Errorf("SendTransaction error: %v", err)
+	}
+	err = rules.VerifyTransaction(tx)
+	if err != nil {
+		return fmt.Errorf("SendTransaction error: %v", err)
+	}
+	tip, err := bc.BlocksDB.GetHeader(bc.CurrentTip())
+	if err != nil {
+		return fmt.Errorf("SendTransaction error: %v", err)
+	}
+	timestamp, err := bc.nextBlockTime(tip)
+	if err != nil {
+		return fmt.Errorf("SendTransaction error: %v", err)
+	}
+	if tx.ExpiredAt(tip.Height+1, timestamp) {
+		return fmt.Errorf("SendTransaction error: transaction %v", tx.expiredReason(tip.Height+1, timestamp))
+	}
+	account, err := bc.AccountsDB.GetAccountOf(tx.From)
+	if err != nil {
+		return fmt.Errorf("SendTransaction error: %v", err)
+	}
+	if tx.Nonce < account.Nonce {
+		return fmt.Errorf("SendTransaction error: nonce too low: %v, next expected nonce of %v is %v",
+			tx.Nonce, tx.From.Hex(true), account.Nonce)
+	}
+	if tx.Size() > bc.Config.MaxBlockBytes || int64(len(tx.Data())) > bc.Config.MaxBlockDataBytes {
+		return fmt.Errorf("SendTransaction error: transaction of %v bytes with %v message bytes can never fit in a block",
+			tx.Size(), len(tx.Data()))
+	}
+	// a pending tx of the same sender and nonce is replaced by tx paying a higher fee
+	var replaced []*DroppedTx
+	var replacedFee int64
+	for _, pendingTx := range bc.TxsPoolDB.GetAllTxs() {
+		if pendingTx.Hash == tx.Hash {
+			return fmt.Errorf("SendTransaction error: transaction %v is already in Txs-Pool", tx.Hash.Hex(true))
+		}
+		if pendingTx.From == tx.From && pendingTx.Nonce == tx.Nonce {
+			replaced = append(replaced, &DroppedTx{Tx: pendingTx, Reason: replacedReason(tx)})
+			if pendingTx.Fee > replacedFee {
+				replacedFee = pendingTx.Fee
+			}
+		}
+	}
+	if len(replaced) > 0 && tx.Fee <= replacedFee {
+		return fmt.Errorf("SendTransaction error: nonce %v of %v is used by a transaction in Txs-Pool, "+
+			"a replacement should pay a fee more than %v", tx.Nonce, tx.From.Hex(true), replacedFee)
+	}
+	// Check if there is enough balance in the account to pay the handling fee and transfer amount
+	balance := account.Balance
+	if balance < tx.Amount()+tx.Fee {
 		return fmt.Errorf("SendTransaction error: "+
 			"your balance (%v) is not enough to cover the handling fee (%v) and amount (%v) you want to transfer",
-			balance, tx.Fee, tx.Amount)
+			balance, tx.Fee, tx.Amount())
+	}
+	if len(replaced) > 0 {
+		err = bc.TxsPoolDB.DropTxs(replaced)
+		if err != nil {
+			return fmt.Errorf("SendTransaction error: %v", err)
+		}
+		for _, d := range replaced {
+			fmt.Printf("🔁 Transaction %v is %v\n", d.Tx.Hash.Hex(true), d.Reason)
+		}
 	}
 	bc.TxsPoolDB.AddTxs([]*Transaction{tx})
 	fmt.Printf("💰 Transaction send!\n")
@@ -68,33 +206,125 @@ func (bc *Blockchain) SendTransaction(tx *Transaction) error {
 	return nil
 }
 
-func (bc *Blockchain) MineBlock(miner common.Address) error {
-	txs := bc.TxsPoolDB.GetSomeTxs(DefaultNumberOfTxsInBlock)
+// GetNextNonce returns the nonce for a new transaction of addr, counting transactions still in Txs-Pool
+func (bc *Blockchain) GetNextNonce(addr common.Address) (uint64, error) {
+	account, err := bc.AccountsDB.GetAccountOf(addr)
+	if err != nil {
+		return 0, fmt.Errorf("GetNextNonce error: %v", err)
+	}
+	nonce := account.Nonce
+	for _, tx := range bc.TxsPoolDB.GetAllTxs() {
+		if tx.From == addr && tx.Nonce >= nonce {
+			nonce = tx.Nonce + 1
+		}
+	}
+	return nonce, nil
+}
+
+// MineBlock pack txs in pool into a new block sealed by the consensus engine,
+// it returns ErrMiningAborted when ctx is done or the tip changes before the block is sealed
+func (bc *Blockchain) MineBlock(ctx context.Context, miner common.Address) error {
+	tipChanged := bc.TipChanged()
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+	go func() {
+		select {
+		case <-tipChanged:
+			cancel()
+		case <-ctx.Done():
+		}
+	}()
+	tip, err := bc.BlocksDB.GetHeader(bc.CurrentTip())
+	if err != nil {
+		fmt.Println("❌ Failed to mine new block")
+		return fmt.Errorf("MineBlock error: %v", err)
+	}
+	timestamp, err := bc.nextBlockTime(tip)
+	if err != nil {
+		fmt.Println("❌ Failed to mine new block")
+		return fmt.Errorf("MineBlock error: %v", err)
+	}
+	// expired txs and txs whose nonce is used are skipped and dropped from pool
+	err = bc.dropUnpackableTxs(tip.Height+1, timestamp)
+	if err != nil {
+		fmt.Println("❌ Failed to mine new block")
+		return fmt.Errorf("MineBlock error: %v", err)
+	}
+	txs := bc.TxsPoolDB.GetTxsWithin(bc.Config.MaxBlockBytes, bc.Config.MaxBlockDataBytes)
 	if len(txs) == 0 {
 		fmt.Println("❌ There is no tx in pool")
 		return fmt.Errorf("there is no tx in pool")
 	}
-	realTxsCount := len(txs)
-	block := NewBlock(txs, bc.Tip)
-	notPackagedTxs, err := block.BePackaged(miner, bc.BlocksDB, bc.AccountsDB)
+	block := NewBlock(txs, tip)
+	block.Header.Timestamp = timestamp
+	err = bc.Engine.Prepare(bc.BlocksDB, &block.Header, tip)
+	if err != nil {
+		fmt.Println("❌ Failed to mine new block")
+		return fmt.Errorf("MineBlock error: %v", err)
+	}
+	notPackagedTxs, err := block.BePackaged(ctx, bc.Engine, bc.Config.Rules(block.Header.Height), miner, bc.AccountsDB)
+	if err == ErrMiningAborted {
+		fmt.Println("⏹ Mining is aborted")
+		return err
+	}
+	if err != nil {
+		fmt.Println("❌ Failed to mine new block")
+		return fmt.Errorf("MineBlock error: %v", err)
+	}
+	isTip, err := bc.InsertBlock(block)
 	if err != nil {
 		fmt.Println("❌ Failed to mine new block")
 		return fmt.Errorf("MineBlock error: %v", err)
 	}
-	bc.TxsPoolDB.DeleteSomeTxs(realTxsCount)
-	bc.TxsPoolDB.LeftAddTxs(notPackagedTxs)
+	// forged txs dropped by BePackaged are removed from pool, txs not packaged are kept for later blocks
+	var dropped []common.Hash
+	kept := make(map[common.Hash]bool)
+	for _, tx := range block.Txs {
+		kept[tx.Hash] = true
+	}
+	for _, tx := range notPackagedTxs {
+		kept[tx.Hash] = true
+	}
+	for _, tx := range txs {
+		if !kept[tx.Hash] {
+			dropped = append(dropped, tx.Hash)
+		}
+	}
+	if len(dropped) > 0 {
+		bc.TxsPoolDB.RemoveTxs(dropped)
+	}
+	if !isTip {
+		fmt.Println("⚠️ Block is mined on a side chain")
+	}
 	fmt.Printf("🔨 New Block Mined!\n")
 	fmt.Println(block.Output())
 	return nil
 }
 
+// GetTxProof returns the Merkle inclusion proof of a packaged transaction
+func (bc *Blockchain) GetTxProof(txHash common.Hash) (*TxProof, error) {
+	blockHash, err := bc.TransactionsDB.GetBlockHashOf(txHash)
+	if err != nil {
+		return nil, fmt.Errorf("GetTxProof error: %v", err)
+	}
+	block, err := bc.BlocksDB.GetBlock(blockHash)
+	if err != nil {
+		return nil, fmt.Errorf("GetTxProof error: %v", err)
+	}
+	proof, err := NewTxProof(block, txHash)
+	if err != nil {
+		return nil, fmt.Errorf("GetTxProof error: %v", err)
+	}
+	return proof, nil
+}
+
 type BlocksIterator struct {
 	currentHash common.Hash
 	db          *BlocksDB
 }
 
 func (bc *Blockchain) BlocksIterator() *BlocksIterator {
-	bci := &BlocksIterator{bc.Tip, bc.BlocksDB}
+	bci := &BlocksIterator{bc.CurrentTip(), bc.BlocksDB}
 	return bci
 }
 
@@ -103,6 +333,41 @@ func (i *BlocksIterator) Next() (*Block, error) {
 	if err != nil {
 		return nil, fmt.Errorf("Next error: %v", err)
 	}
-	i.currentHash = block.PrevBlockHash
+	i.currentHash = block.Header.PrevBlockHash
+	return block, nil
+}
+
+// ForwardBlocksIterator iterate over blocks of the main chain from a lower height to a higher one
+type ForwardBlocksIterator struct {
+	height int64
+	to     int64
+	db     *BlocksDB
+}
+
+// ForwardBlocksIterator blocks at heights in [from, to] on the main chain, to is limited to the height of the tip
+func (bc *Blockchain) ForwardBlocksIterator(from, to int64) (*ForwardBlocksIterator, error) {
+	tip, err := bc.BlocksDB.GetHeader(bc.CurrentTip())
+	if err != nil {
+		return nil, fmt.Errorf("ForwardBlocksIterator error: %v", err)
+	}
+	if from < 0 {
+		from = 0
+	}
+	if to > tip.Height {
+		to = tip.Height
+	}
+	return &ForwardBlocksIterator{from, to, bc.BlocksDB}, nil
+}
+
+func (i *ForwardBlocksIterator) HasNext() bool {
+	return i.height <= i.to
+}
+
+func (i *ForwardBlocksIterator) Next() (*Block, error) {
+	block, err := i.db.GetBlockByHeight(i.height)
+	if err != nil {
+		return nil, fmt.Errorf("Next error: %v", err)
+	}
+	i.height++
 	return block, nil
 }
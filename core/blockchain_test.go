@@ -0,0 +1,170 @@
+package core
+
+import (
+	"context"
+	"crypto/ecdsa"
+	"fmt"
+	"github.com/XiaoYao-0/memory-blockchain/common"
+	"os"
+	"sync"
+	"testing"
+	"time"
+)
+
+// testKeys keys of accounts funded by newTestBlockchain
+var testKeys = func() []*ecdsa.PrivateKey {
+	var keys []*ecdsa.PrivateKey
+	for i := 1; i <= 3; i++ {
+		key, err := common.NewPrivateKey(fmt.Sprintf("0x%064x", i))
+		if err != nil {
+			panic(err)
+		}
+		keys = append(keys, key)
+	}
+	return keys
+}()
+
+var testMiner = common.Address{0x08}
+
+func testAddress(key *ecdsa.PrivateKey) common.Address {
+	return common.PubKeyToAddress(&key.PublicKey)
+}
+
+// newTestBlockchain open a chain of the dev engine in a temporary directory with every key of testKeys funded,
+// the working directory is changed to it because ChainDBFile is relative
+func newTestBlockchain(t *testing.T, configure func(genesis *Genesis)) *Blockchain {
+	wd, err := os.Getwd()
+	if err != nil {
+		t.Fatal(err)
+	}
+	dir := t.TempDir()
+	err = os.Mkdir(dir+"/data", 0755)
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = os.Chdir(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { _ = os.Chdir(wd) })
+	genesis := DefaultGenesis()
+	genesis.Config.Engine = EngineDev
+	for _, key := range testKeys {
+		genesis.Alloc[testAddress(key)] = 1000000
+	}
+	if configure != nil {
+		configure(genesis)
+	}
+	bc, err := NewBlockchain(genesis)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(bc.CloseDB)
+	return bc
+}
+
+// newTestTx a signed transfer of key with the next nonce
+func newTestTx(t *testing.T, bc *Blockchain, key *ecdsa.PrivateKey, amount, fee int64) *Transaction {
+	nonce, err := bc.GetNextNonce(testAddress(key))
+	if err != nil {
+		t.Fatal(err)
+	}
+	return newTestTxWithNonce(t, bc, key, amount, fee, nonce)
+}
+
+func newTestTxWithNonce(t *testing.T, bc *Blockchain, key *ecdsa.PrivateKey, amount, fee int64, nonce uint64) *Transaction {
+	rules, err := bc.NextRules()
+	if err != nil {
+		t.Fatal(err)
+	}
+	tx, err := NewTransaction(rules, testAddress(key), testMiner, "", amount, fee, 0, nonce)
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = tx.Sign(key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return tx
+}
+
+func mineTestBlock(t *testing.T, bc *Blockchain) {
+	err := bc.MineBlock(context.Background(), testMiner)
+	if err != nil {
+		t.Fatal(err)
+	}
+}
+
+func tipHeight(t *testing.T, bc *Blockchain) int64 {
+	header, err := bc.BlocksDB.GetHeader(bc.CurrentTip())
+	if err != nil {
+		t.Fatal(err)
+	}
+	return header.Height
+}
+
+// TestConcurrentMining mines, sends txs and reads the tip from different goroutines, run it with
+// go test -race -gcflags=all=-d=checkptr=0 ./core, the unsafe casts of bolt v1.3.1 fail the pointer checks of -race
+func TestConcurrentMining(t *testing.T) {
+	bc := newTestBlockchain(t, nil)
+	const txs = 20
+	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+	defer cancel()
+	var wg sync.WaitGroup
+	sent := make(chan struct{})
+	wg.Add(3)
+	go func() {
+		defer wg.Done()
+		defer close(sent)
+		key := testKeys[0]
+		for i := 0; i < txs; i++ {
+			nonce, err := bc.GetNextNonce(testAddress(key))
+			if err != nil {
+				t.Error(err)
+				return
+			}
+			err = bc.SendTransaction(newTestTxWithNonce(t, bc, key, 1, 0, nonce))
+			if err != nil {
+				t.Error(err)
+				return
+			}
+		}
+	}()
+	go func() {
+		defer wg.Done()
+		// blocks are mined until every tx is packaged, errors of an empty pool are expected
+		for ctx.Err() == nil {
+			_ = bc.MineBlock(ctx, testMiner)
+			account, err := bc.AccountsDB.GetAccountOf(testAddress(testKeys[0]))
+			if err == nil && account.Nonce == txs {
+				return
+			}
+			time.Sleep(time.Millisecond)
+		}
+	}()
+	go func() {
+		defer wg.Done()
+		for {
+			select {
+			case <-sent:
+				return
+			default:
+			}
+			_, _ = bc.NextRules()
+			_, _ = bc.EstimateFee(DefaultFeeEstimateBlocks, DefaultFeeEstimateTxSize)
+			_, _ = bc.GetCheckpoint()
+			_, _ = bc.BlocksIterator().Next()
+			_ = bc.TxsPoolDB.Output()
+		}
+	}()
+	wg.Wait()
+	if ctx.Err() != nil {
+		t.Fatal("txs are not packaged in time")
+	}
+	if pending := bc.TxsPoolDB.GetAllTxs(); len(pending) != 0 {
+		t.Fatalf("%v txs are left in pool", len(pending))
+	}
+	if _, err := bc.Verify(); err != nil {
+		t.Fatal(err)
+	}
+}
@@ -0,0 +1,118 @@
+package core
+
+import (
+	"encoding/json"
+	"fmt"
+	"github.com/XiaoYao-0/memory-blockchain/common"
+	"github.com/boltdb/bolt"
+)
+
+// Checkpoint a block trusted by the config, no chain conflicting with it is accepted
+type Checkpoint struct {
+	Height int64  `json:"height"`
+	Hash   string `json:"hash"` // with prefix "0x"
+}
+
+// validateCheckpoints checkpoints should be in order of heights with legal hashes
+func (c *ChainConfig) validateCheckpoints() error {
+	lastHeight := int64(-1)
+	for _, checkpoint := range c.Checkpoints {
+		if checkpoint.Height <= lastHeight {
+			return fmt.Errorf("checkpoints should be in strictly increasing order of heights")
+		}
+		lastHeight = checkpoint.Height
+		_, err := common.NewHash(checkpoint.Hash)
+		if err != nil {
+			return fmt.Errorf("illegal hash of checkpoint at height %v: %v", checkpoint.Height, err)
+		}
+	}
+	return nil
+}
+
+// checkpointAt the hash of the checkpoint at height, false if there is no checkpoint at height
+func (c *ChainConfig) checkpointAt(height int64) (common.Hash, bool) {
+	for _, checkpoint := range c.Checkpoints {
+		if checkpoint.Height == height {
+			hash, err := common.NewHash(checkpoint.Hash)
+			return hash, err == nil
+		}
+	}
+	return common.Hash{}, false
+}
+
+// LastCheckpointHeight the height of the latest checkpoint, -1 if there is no checkpoint
+func (c *ChainConfig) LastCheckpointHeight() int64 {
+	if len(c.Checkpoints) == 0 {
+		return -1
+	}
+	return c.Checkpoints[len(c.Checkpoints)-1].Height
+}
+
+// verifyCheckpoint a block at the height of a checkpoint should be the checkpoint
+func (c *ChainConfig) verifyCheckpoint(height int64, hash common.Hash) error {
+	if expected, ok := c.checkpointAt(height); ok && expected != hash {
+		return fmt.Errorf("block %v conflicts with checkpoint %v at height %v", hash.Hex(true), expected.Hex(true), height)
+	}
+	return nil
+}
+
+// verifyHeaders walk the main chain from tip to genesis on startup and check prev-hash links, heights and checkpoints.
+// Headers above the latest checkpoint are verified by the consensus engine, those below it are trusted because
+// the checkpoint commits to them through prev-hash links
+func (bc *Blockchain) verifyHeaders() error {
+	lastCheckpoint := bc.Config.LastCheckpointHeight()
+	err := bc.DB.View(func(tx *bolt.Tx) error {
+		hash, txError := getTip(tx)
+		if txError != nil {
+			return txError
+		}
+		header, txError := getHeader(tx, hash)
+		if txError != nil {
+			return txError
+		}
+		for header.Height > 0 {
+			if header.Hash() != hash {
+				return fmt.Errorf("header %v does not match its hash", hash.Hex(true))
+			}
+			txError = bc.Config.verifyCheckpoint(header.Height, hash)
+			if txError != nil {
+				return txError
+			}
+			parent, txError := getHeader(tx, header.PrevBlockHash)
+			if txError != nil {
+				return txError
+			}
+			if header.Height != parent.Height+1 {
+				return fmt.Errorf("height of block %v is %v, expected %v", hash.Hex(true), header.Height, parent.Height+1)
+			}
+			if header.Height > lastCheckpoint {
+				txError = bc.Engine.VerifyHeader(&txHeaderReader{tx}, header, parent)
+				if txError != nil {
+					return fmt.Errorf("block %v at height %v: %v", hash.Hex(true), header.Height, txError)
+				}
+			}
+			hash, header = header.PrevBlockHash, parent
+		}
+		if hash != NewGenesisBlock().Hash {
+			return fmt.Errorf("genesis block %v does not match the expected %v", hash.Hex(true), NewGenesisBlock().Hash.Hex(true))
+		}
+		return nil
+	})
+	if err != nil {
+		return fmt.Errorf("verifyHeaders error: %v", err)
+	}
+	return nil
+}
+
+// GetCheckpoint a checkpoint of the current tip which can be added to the config of genesis.json
+func (bc *Blockchain) GetCheckpoint() (string, error) {
+	header, err := bc.BlocksDB.GetHeader(bc.CurrentTip())
+	if err != nil {
+		return "", fmt.Errorf("GetCheckpoint error: %v", err)
+	}
+	checkpointJSON, err := json.Marshal(Checkpoint{Height: header.Height, Hash: header.Hash().Hex(true)})
+	if err != nil {
+		return "", fmt.Errorf("GetCheckpoint error: %v", err)
+	}
+	return string(checkpointJSON), nil
+}
@@ -0,0 +1,107 @@
+package core
+
+import (
+	"fmt"
+	"github.com/XiaoYao-0/memory-blockchain/common"
+)
+
+// Coinbase the payload paying the reward of a block to its miner, the transaction carrying it is the first one of the block
+type Coinbase struct {
+	Miner   common.Address
+	Height  int64 // height of the block, so coinbase transactions of different blocks have different hashes
+	Subsidy int64
+	Fees    int64 // fees of the block paid to the miner
+	Burned  int64 // fees of the block burned
+}
+
+// NewCoinbaseTransaction create the transaction paying reward to the miner of header, it is not signed
+func NewCoinbaseTransaction(header *BlockHeader, reward Reward) *Transaction {
+	tx := &Transaction{
+		Version: TxVersion,
+		From:    common.Address{},
+		Payload: &Coinbase{
+			Miner:   header.Miner,
+			Height:  header.Height,
+			Subsidy: reward.Subsidy,
+			Fees:    reward.Fees,
+			Burned:  reward.Burned,
+		},
+	}
+	tx.Hash = tx.CalcHash()
+	return tx
+}
+
+func (c *Coinbase) Type() TxType  { return TxCoinbase }
+func (c *Coinbase) amount() int64 { return c.Subsidy + c.Fees }
+func (c *Coinbase) data() []byte  { return nil }
+
+func (c *Coinbase) encode() []byte {
+	e := &payloadEncoder{}
+	e.address(c.Miner)
+	e.int64(c.Height)
+	e.int64(c.Subsidy)
+	e.int64(c.Fees)
+	e.int64(c.Burned)
+	return e.buf.Bytes()
+}
+
+func (c *Coinbase) decode(d *payloadDecoder) {
+	c.Miner = d.address()
+	c.Height = d.int64()
+	c.Subsidy = d.int64()
+	c.Fees = d.int64()
+	c.Burned = d.int64()
+}
+
+func (c *Coinbase) verify(rules *Rules) error {
+	return nil
+}
+
+func (c *Coinbase) exec(state State) error {
+	return credit(state, c.Miner, c.amount(), nil)
+}
+
+func (c *Coinbase) output() string {
+	return fmt.Sprintf("  Miner: %v\n  Amount: %v\n  Coinbase: height %v, subsidy %v, fees %v, burned %v\n",
+		c.Miner.Hex(true), c.amount(), c.Height, c.Subsidy, c.Fees, c.Burned)
+}
+
+func (tx *Transaction) IsCoinbase() bool {
+	return tx.coinbase() != nil
+}
+
+// coinbase the reward paid by tx, nil if tx is not a coinbase transaction
+func (tx *Transaction) coinbase() *Coinbase {
+	coinbase, _ := tx.Payload.(*Coinbase)
+	return coinbase
+}
+
+// verifyCoinbase check tx pays reward to the miner of header
+func verifyCoinbase(tx *Transaction, header *BlockHeader, reward Reward) error {
+	if tx.CalcHash() != tx.Hash {
+		return fmt.Errorf("verifyCoinbase error: hash mismatch of coinbase transaction %v", tx.Hash.Hex(true))
+	}
+	expected := Coinbase{
+		Miner:   header.Miner,
+		Height:  header.Height,
+		Subsidy: reward.Subsidy,
+		Fees:    reward.Fees,
+		Burned:  reward.Burned,
+	}
+	coinbase := tx.coinbase()
+	if coinbase == nil || *coinbase != expected || tx.Amount() != reward.Total() || len(tx.Data()) != 0 ||
+		tx.From != (common.Address{}) || tx.Fee != 0 || tx.Nonce != 0 {
+		return fmt.Errorf("verifyCoinbase error: coinbase should pay %v (subsidy %v, fees %v, burned %v) to %v at height %v",
+			reward.Total(), reward.Subsidy, reward.Fees, reward.Burned, header.Miner.Hex(true), header.Height)
+	}
+	return nil
+}
+
+// execCoinbase credit the miner, tx should have been checked by verifyCoinbase
+func execCoinbase(tx *Transaction, state State) error {
+	err := tx.Payload.exec(state)
+	if err != nil {
+		return fmt.Errorf("execCoinbase error: %v", err)
+	}
+	return nil
+}
@@ -0,0 +1,126 @@
+package core
+
+import (
+	"fmt"
+)
+
+// ChainConfig consensus parameters, every node of a chain should use the same config
+type ChainConfig struct {
+	Engine              string           `json:"engine"`              // "pow" (default), "poa" or "dev"
+	Signers             []string         `json:"signers"`             // signers taking turns to seal blocks of the "poa" engine
+	TargetBlockInterval int64            `json:"targetBlockInterval"` // seconds between blocks difficulty is adjusted toward
+	RetargetWindow      int64            `json:"retargetWindow"`      // difficulty is adjusted once every RetargetWindow blocks
+	InitialBits         int64            `json:"initialBits"`         // difficulty of the first window
+	MinBits             int64            `json:"minBits"`
+	MaxBits             int64            `json:"maxBits"`
+	InitialSubsidy      int64            `json:"initialSubsidy"`     // coins minted by each block before the first halving
+	HalvingInterval     int64            `json:"halvingInterval"`    // subsidy is halved every HalvingInterval blocks, 0 for never
+	MaxSupply           int64            `json:"maxSupply"`          // limit of coins minted by blocks, genesis alloc excluded, 0 for no limit
+	FeeBurnPercent      int64            `json:"feeBurnPercent"`     // percentage of fees burned instead of being paid to the miner
+	MaxBlockBytes       int64            `json:"maxBlockBytes"`      // limit of the serialized size of all txs in a block
+	MaxBlockDataBytes   int64            `json:"maxBlockDataBytes"`  // limit of the message bytes of all txs in a block
+	MedianTimeSpan      int64            `json:"medianTimeSpan"`     // timestamp should be greater than the median of so many previous blocks
+	MaxFutureDrift      int64            `json:"maxFutureDrift"`     // seconds a timestamp may be ahead of the local clock
+	PowAlgorithm        string           `json:"powAlgorithm"`       // "sha256" (default) or "scrypt"
+	PowAlgorithmHeight  int64            `json:"powAlgorithmHeight"` // PowAlgorithm is used from this height, "sha256" before
+	ScryptN             int64            `json:"scryptN"`            // CPU/memory cost of scrypt, a power of 2
+	ScryptR             int64            `json:"scryptR"`            // block size of scrypt
+	ScryptP             int64            `json:"scryptP"`            // parallelization of scrypt
+	PowInitialBits      map[string]int64 `json:"powInitialBits"`     // difficulty of the first window or from a switch to an algorithm, initialBits if not set
+	Forks               []Fork           `json:"forks"`              // hard forks in order of activation heights
+	Checkpoints         []Checkpoint     `json:"checkpoints"`        // trusted blocks in order of heights
+}
+
+const (
+	// MaxBitsAdjustment difficulty changes at most 2^MaxBitsAdjustment times in one retarget
+	MaxBitsAdjustment = 2
+)
+
+func DefaultChainConfig() *ChainConfig {
+	return &ChainConfig{
+		Engine:              EngineProofOfWork,
+		TargetBlockInterval: 30,
+		RetargetWindow:      10,
+		InitialBits:         24,
+		MinBits:             1,
+		MaxBits:             255,
+		InitialSubsidy:      MinerAwardForOneBlock,
+		MaxBlockBytes:       16 * 1024,
+		MaxBlockDataBytes:   4 * 1024,
+		MedianTimeSpan:      11,
+		MaxFutureDrift:      15 * 60,
+		PowAlgorithm:        PowSHA256,
+		ScryptN:             1024,
+		ScryptR:             1,
+		ScryptP:             1,
+		// scrypt with the default parameters is about 2^9 times slower than sha256
+		PowInitialBits: map[string]int64{PowScrypt: 15},
+	}
+}
+
+func (c *ChainConfig) Validate() error {
+	switch c.Engine {
+	case EngineProofOfWork, EngineDev:
+	case EngineProofOfAuthority:
+		if len(c.Signers) == 0 {
+			return fmt.Errorf("signers should not be empty for engine %v", c.Engine)
+		}
+	default:
+		return fmt.Errorf("unknown engine %v", c.Engine)
+	}
+	if c.TargetBlockInterval <= 0 {
+		return fmt.Errorf("targetBlockInterval should be more than 0")
+	}
+	if c.RetargetWindow < 2 {
+		return fmt.Errorf("retargetWindow should be at least 2")
+	}
+	if c.MinBits < 0 || c.MaxBits > 255 || c.MinBits > c.MaxBits {
+		return fmt.Errorf("minBits and maxBits should satisfy 0 <= minBits <= maxBits <= 255")
+	}
+	if c.InitialBits < c.MinBits || c.InitialBits > c.MaxBits {
+		return fmt.Errorf("initialBits should be between minBits and maxBits")
+	}
+	if c.InitialSubsidy < 0 || c.HalvingInterval < 0 || c.MaxSupply < 0 {
+		return fmt.Errorf("initialSubsidy, halvingInterval and maxSupply should not be less than 0")
+	}
+	if c.FeeBurnPercent < 0 || c.FeeBurnPercent > 100 {
+		return fmt.Errorf("feeBurnPercent should be between 0 and 100")
+	}
+	if c.MaxBlockBytes <= 0 || c.MaxBlockDataBytes <= 0 {
+		return fmt.Errorf("maxBlockBytes and maxBlockDataBytes should be more than 0")
+	}
+	if c.MedianTimeSpan <= 0 || c.MaxFutureDrift < 0 {
+		return fmt.Errorf("medianTimeSpan should be more than 0 and maxFutureDrift should not be less than 0")
+	}
+	switch c.PowAlgorithm {
+	case PowSHA256, PowScrypt:
+	default:
+		return fmt.Errorf("unknown powAlgorithm %v", c.PowAlgorithm)
+	}
+	if c.PowAlgorithmHeight < 0 {
+		return fmt.Errorf("powAlgorithmHeight should not be less than 0")
+	}
+	if c.ScryptN <= 1 || c.ScryptN&(c.ScryptN-1) != 0 || c.ScryptN > 1<<20 {
+		return fmt.Errorf("scryptN should be a power of 2 between 2 and 2^20")
+	}
+	if c.ScryptR <= 0 || c.ScryptP <= 0 || c.ScryptR*c.ScryptP >= 1<<30 {
+		return fmt.Errorf("scryptR and scryptP should be more than 0 and scryptR*scryptP should be less than 2^30")
+	}
+	for algorithm, bits := range c.PowInitialBits {
+		if algorithm != PowSHA256 && algorithm != PowScrypt {
+			return fmt.Errorf("unknown powAlgorithm %v in powInitialBits", algorithm)
+		}
+		if bits < c.MinBits || bits > c.MaxBits {
+			return fmt.Errorf("powInitialBits of %v should be between minBits and maxBits", algorithm)
+		}
+	}
+	err := c.validateForks()
+	if err != nil {
+		return err
+	}
+	err = c.validateCheckpoints()
+	if err != nil {
+		return err
+	}
+	return nil
+}
@@ -0,0 +1,58 @@
+package core
+
+import (
+	"context"
+	"fmt"
+	"github.com/XiaoYao-0/memory-blockchain/common"
+	"github.com/boltdb/bolt"
+)
+
+const (
+	EngineProofOfWork      = "pow"
+	EngineProofOfAuthority = "poa"
+	EngineDev              = "dev"
+)
+
+// HeaderReader gives engines access to the headers of the chain
+type HeaderReader interface {
+	GetHeader(hash common.Hash) (*BlockHeader, error)
+}
+
+// txHeaderReader read headers in a bolt transaction
+type txHeaderReader struct {
+	tx *bolt.Tx
+}
+
+func (r *txHeaderReader) GetHeader(hash common.Hash) (*BlockHeader, error) {
+	return getHeader(r.tx, hash)
+}
+
+// ConsensusEngine decides who may create a block and how it is sealed
+type ConsensusEngine interface {
+	// Prepare set the consensus fields of header, such as Bits, before txs are packaged
+	Prepare(chain HeaderReader, header, parent *BlockHeader) error
+	// Seal complete header so that VerifyHeader accepts it, it returns ErrMiningAborted if ctx is done first
+	Seal(ctx context.Context, header *BlockHeader) error
+	// VerifyHeader check the consensus fields and the seal of header on top of parent
+	VerifyHeader(chain HeaderReader, header, parent *BlockHeader) error
+	// CalculateReward the reward of the miner of header which packages txs paying fees in total
+	CalculateReward(header *BlockHeader, fees int64) Reward
+}
+
+// NewConsensusEngine create the engine named by config.Engine
+func NewConsensusEngine(config *ChainConfig) (ConsensusEngine, error) {
+	switch config.Engine {
+	case EngineProofOfWork, "":
+		return NewPowEngine(config), nil
+	case EngineProofOfAuthority:
+		engine, err := NewPoAEngine(config)
+		if err != nil {
+			return nil, fmt.Errorf("NewConsensusEngine error: %v", err)
+		}
+		return engine, nil
+	case EngineDev:
+		return NewDevEngine(config), nil
+	default:
+		return nil, fmt.Errorf("NewConsensusEngine error: unknown engine %v", config.Engine)
+	}
+}
@@ -0,0 +1,38 @@
+package core
+
+import (
+	"context"
+	"fmt"
+)
+
+// DevEngine seal blocks instantly without any work or signature, for tests and local development only
+type DevEngine struct {
+	config *ChainConfig
+}
+
+func NewDevEngine(config *ChainConfig) *DevEngine {
+	return &DevEngine{config: config}
+}
+
+func (e *DevEngine) Prepare(chain HeaderReader, header, parent *BlockHeader) error {
+	header.Bits = 0
+	return nil
+}
+
+func (e *DevEngine) Seal(ctx context.Context, header *BlockHeader) error {
+	if ctx.Err() != nil {
+		return ErrMiningAborted
+	}
+	return nil
+}
+
+func (e *DevEngine) VerifyHeader(chain HeaderReader, header, parent *BlockHeader) error {
+	if header.Bits != 0 || len(header.Seal) != 0 {
+		return fmt.Errorf("VerifyHeader error: dev block should have no bits and seal")
+	}
+	return nil
+}
+
+func (e *DevEngine) CalculateReward(header *BlockHeader, fees int64) Reward {
+	return e.config.CalcReward(header.Height, fees)
+}
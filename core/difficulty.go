@@ -0,0 +1,83 @@
+package core
+
+import (
+	"fmt"
+)
+
+// calcNextBits the difficulty of the child of parent.
+//
+// Blocks of the first window, and the first block after the proof-of-work algorithm changes, use the PowInitialBits
+// of their algorithm, since the hash rates of algorithms differ by orders of magnitude.
+// The first block of every later window compares the time the previous window took, from the switch of algorithm
+// at the earliest, with the expected time of as many blocks at TargetBlockInterval: Bits is increased by one
+// for every halving of the expected time and decreased by one for every doubling, at most MaxBitsAdjustment
+// each way. Other blocks keep the Bits of their parent.
+func calcNextBits(chain HeaderReader, config *ChainConfig, parent *BlockHeader) (int64, error) {
+	height := parent.Height + 1
+	start := config.powAlgorithmStart(height)
+	if start == height || (start == 0 && height <= config.RetargetWindow) {
+		return config.powInitialBits(config.Rules(height).PowAlgorithm), nil
+	}
+	if height%config.RetargetWindow != 1 {
+		return parent.Bits, nil
+	}
+	// first block of the previous window, blocks of the previous algorithm are not counted
+	first := parent
+	for first.Height > height-config.RetargetWindow && first.Height > start {
+		var err error
+		first, err = chain.GetHeader(first.PrevBlockHash)
+		if err != nil {
+			return 0, fmt.Errorf("calcNextBits error: %v", err)
+		}
+	}
+	if first.Height == parent.Height {
+		return parent.Bits, nil
+	}
+	actual := parent.Timestamp - first.Timestamp
+	expected := (parent.Height - first.Height) * config.TargetBlockInterval
+	if actual < 1 {
+		actual = 1
+	}
+	bits := parent.Bits
+	for i := 0; i < MaxBitsAdjustment && actual*2 <= expected; i++ {
+		actual *= 2
+		bits++
+	}
+	for i := 0; i < MaxBitsAdjustment && actual >= expected*2; i++ {
+		actual /= 2
+		bits--
+	}
+	if bits < config.MinBits {
+		bits = config.MinBits
+	}
+	if bits > config.MaxBits {
+		bits = config.MaxBits
+	}
+	return bits, nil
+}
+
+// powAlgorithmStart the first height of the run of blocks up to height using the proof-of-work algorithm of height
+func (c *ChainConfig) powAlgorithmStart(height int64) int64 {
+	activations := []int64{c.PowAlgorithmHeight}
+	for _, fork := range c.Forks {
+		if fork.PowAlgorithm != nil {
+			activations = append(activations, fork.Height)
+		}
+	}
+	var start int64
+	for _, activation := range activations {
+		if activation > start && activation <= height &&
+			c.Rules(activation).PowAlgorithm != c.Rules(activation-1).PowAlgorithm {
+			start = activation
+		}
+	}
+	return start
+}
+
+// powInitialBits the PowInitialBits of algorithm, InitialBits if it is not set
+func (c *ChainConfig) powInitialBits(algorithm string) int64 {
+	if bits, ok := c.PowInitialBits[algorithm]; ok {
+		return bits
+	}
+	return c.InitialBits
+}
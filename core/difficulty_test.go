@@ -0,0 +1,119 @@
+package core
+
+import (
+	"github.com/XiaoYao-0/memory-blockchain/common"
+	"testing"
+)
+
+type testHeaderReader map[common.Hash]*BlockHeader
+
+func (r testHeaderReader) GetHeader(hash common.Hash) (*BlockHeader, error) {
+	return r[hash], nil
+}
+
+func TestCalcNextBits(t *testing.T) {
+	scrypt, sha256 := PowScrypt, PowSHA256
+	every := func(seconds int64) func(int64) int64 {
+		return func(int64) int64 { return seconds }
+	}
+	tests := []struct {
+		name      string
+		configure func(c *ChainConfig)
+		interval  func(height int64) int64 // seconds between the block at height and its parent
+		bits      map[int64]int64          // expected bits by height
+	}{
+		{
+			name:      "sha256 from genesis",
+			configure: func(c *ChainConfig) {},
+			interval:  every(30),
+			bits:      map[int64]int64{1: 24, 10: 24, 11: 24, 21: 24},
+		},
+		{
+			name: "scrypt from genesis",
+			configure: func(c *ChainConfig) {
+				c.PowAlgorithm, c.PowAlgorithmHeight = PowScrypt, 0
+			},
+			interval: every(30),
+			bits:     map[int64]int64{1: 15, 10: 15, 11: 15, 21: 15},
+		},
+		{
+			name: "scrypt from height 1",
+			configure: func(c *ChainConfig) {
+				c.PowAlgorithm, c.PowAlgorithmHeight = PowScrypt, 1
+			},
+			interval: every(30),
+			bits:     map[int64]int64{1: 15, 10: 15, 11: 15, 21: 15},
+		},
+		{
+			// the long gap before the switch is not counted by the retarget of height 31
+			name: "scrypt from the middle of a window",
+			configure: func(c *ChainConfig) {
+				c.PowAlgorithm, c.PowAlgorithmHeight = PowScrypt, 25
+			},
+			interval: func(height int64) int64 {
+				if height == 25 {
+					return 10000
+				}
+				return 30
+			},
+			bits: map[int64]int64{24: 24, 25: 15, 26: 15, 30: 15, 31: 15, 41: 15},
+		},
+		{
+			name: "faster blocks after the switch",
+			configure: func(c *ChainConfig) {
+				c.PowAlgorithm, c.PowAlgorithmHeight = PowScrypt, 25
+			},
+			interval: func(height int64) int64 {
+				if height >= 25 {
+					return 15
+				}
+				return 30
+			},
+			bits: map[int64]int64{24: 24, 25: 15, 30: 15, 31: 16, 41: 17},
+		},
+		{
+			name: "fork back to sha256",
+			configure: func(c *ChainConfig) {
+				c.PowAlgorithm, c.PowAlgorithmHeight = PowScrypt, 25
+				c.Forks = []Fork{{Name: "sha256Again", Height: 35, PowAlgorithm: &sha256}}
+			},
+			interval: every(30),
+			bits:     map[int64]int64{25: 15, 34: 15, 35: 24, 40: 24, 41: 24},
+		},
+		{
+			name: "fork to the algorithm already active",
+			configure: func(c *ChainConfig) {
+				c.PowAlgorithm, c.PowAlgorithmHeight = PowScrypt, 0
+				c.Forks = []Fork{{Name: "scryptAgain", Height: 15, PowAlgorithm: &scrypt}}
+			},
+			interval: every(15),
+			bits:     map[int64]int64{1: 15, 11: 16, 15: 16, 21: 17},
+		},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			config := DefaultChainConfig()
+			test.configure(config)
+			chain := testHeaderReader{}
+			parent := &BlockHeader{Height: 0, Bits: config.InitialBits, Timestamp: GenesisTimestamp}
+			chain[parent.Hash()] = parent
+			for height := int64(1); height <= 45; height++ {
+				bits, err := calcNextBits(chain, config, parent)
+				if err != nil {
+					t.Fatal(err)
+				}
+				if expected, ok := test.bits[height]; ok && bits != expected {
+					t.Fatalf("bits of height %v = %v, expected %v", height, bits, expected)
+				}
+				header := &BlockHeader{
+					Height:        height,
+					Bits:          bits,
+					Timestamp:     parent.Timestamp + test.interval(height),
+					PrevBlockHash: parent.Hash(),
+				}
+				chain[header.Hash()] = header
+				parent = header
+			}
+		})
+	}
+}
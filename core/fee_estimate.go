@@ -0,0 +1,135 @@
+package core
+
+import (
+	"fmt"
+	"math/big"
+	"sort"
+)
+
+const (
+	DefaultFeeEstimateBlocks = 10  // recent blocks looked at by EstimateFee
+	DefaultFeeEstimateTxSize = 512 // about the serialized size of a transfer without message
+	FeeRateBytes             = 1000
+)
+
+// FeeEstimate fee rates offered by recent blocks and the TxsPool, in fee per FeeRateBytes of serialized size
+type FeeEstimate struct {
+	Blocks     int   // recent blocks of the main chain looked at
+	RecentRate int64 // median of the lowest fee rate packaged in each recent block with txs, 0 if there is none
+	PendingTxs int   // txs in the TxsPool
+	PoolRate   int64 // fee rate outbidding the lowest one selected for the next block if the pool does not fit in it, otherwise 0
+	Rate       int64 // the higher one of the fee rates above
+	Size       int64 // serialized size of the transaction to be sent
+	MinFee     int64 // minimum fee of a transfer in the next block
+	Suggested  int64 // fee of Size bytes at Rate, at least MinFee
+}
+
+// EstimateFee suggest a fee for a transaction of size bytes to be packaged soon, looking at the fee rates of
+// the last blocks of the main chain and of the txs the next block would select from the TxsPool
+func (bc *Blockchain) EstimateFee(blocks int, size int64) (*FeeEstimate, error) {
+	if blocks <= 0 || size <= 0 {
+		return nil, fmt.Errorf("EstimateFee error: blocks and size should be more than 0")
+	}
+	tip, err := bc.BlocksDB.GetHeader(bc.CurrentTip())
+	if err != nil {
+		return nil, fmt.Errorf("EstimateFee error: %v", err)
+	}
+	rules := bc.Config.Rules(tip.Height + 1)
+	minFee, err := rules.MinFee(0, 0)
+	if err != nil {
+		return nil, fmt.Errorf("EstimateFee error: %v", err)
+	}
+	estimate := &FeeEstimate{Size: size, MinFee: minFee}
+	// the lowest fee rate of each block is what was enough to be packaged
+	bi, err := bc.ForwardBlocksIterator(tip.Height-int64(blocks)+1, tip.Height)
+	if err != nil {
+		return nil, fmt.Errorf("EstimateFee error: %v", err)
+	}
+	var lowestRates []int64
+	for bi.HasNext() {
+		block, err := bi.Next()
+		if err != nil {
+			return nil, fmt.Errorf("EstimateFee error: %v", err)
+		}
+		if block.Header.Height == 0 {
+			continue
+		}
+		estimate.Blocks++
+		if lowest, ok := lowestFeeRate(block.Txs); ok {
+			lowestRates = append(lowestRates, lowest)
+		}
+	}
+	if len(lowestRates) != 0 {
+		sort.Slice(lowestRates, func(i, j int) bool { return lowestRates[i] < lowestRates[j] })
+		estimate.RecentRate = lowestRates[len(lowestRates)/2]
+	}
+	// a pool larger than a block needs a fee rate beating the lowest one the next block would select
+	pending := bc.TxsPoolDB.GetAllTxs()
+	estimate.PendingTxs = len(pending)
+	selected := bc.TxsPoolDB.GetTxsWithin(bc.Config.MaxBlockBytes, bc.Config.MaxBlockDataBytes)
+	if len(selected) < len(pending) {
+		if lowest, ok := lowestFeeRate(selected); ok {
+			estimate.PoolRate = lowest + 1
+		}
+	}
+	estimate.Rate = estimate.RecentRate
+	if estimate.PoolRate > estimate.Rate {
+		estimate.Rate = estimate.PoolRate
+	}
+	estimate.Suggested = feeAtRate(estimate.Rate, size)
+	if estimate.Suggested < estimate.MinFee {
+		estimate.Suggested = estimate.MinFee
+	}
+	return estimate, nil
+}
+
+// lowestFeeRate the lowest fee rate of txs except the coinbase, false if there is no such tx
+func lowestFeeRate(txs []*Transaction) (int64, bool) {
+	var lowest int64
+	found := false
+	for _, tx := range txs {
+		if tx.IsCoinbase() {
+			continue
+		}
+		rate := feeRate(tx.Fee, tx.Size())
+		if !found || rate < lowest {
+			lowest = rate
+			found = true
+		}
+	}
+	return lowest, found
+}
+
+// feeRate fee per FeeRateBytes of size, rounded up
+func feeRate(fee, size int64) int64 {
+	rate := new(big.Int).Mul(big.NewInt(fee), big.NewInt(FeeRateBytes))
+	rate.Add(rate, big.NewInt(size-1))
+	return rate.Quo(rate, big.NewInt(size)).Int64()
+}
+
+// feeAtRate fee of size bytes at rate, rounded up
+func feeAtRate(rate, size int64) int64 {
+	fee := new(big.Int).Mul(big.NewInt(rate), big.NewInt(size))
+	fee.Add(fee, big.NewInt(FeeRateBytes-1))
+	return fee.Quo(fee, big.NewInt(FeeRateBytes)).Int64()
+}
+
+// higherFeeRate whether fee1 for size1 bytes pays more per byte than fee2 for size2 bytes
+func higherFeeRate(fee1, size1, fee2, size2 int64) bool {
+	rate1 := new(big.Int).Mul(big.NewInt(fee1), big.NewInt(size2))
+	rate2 := new(big.Int).Mul(big.NewInt(fee2), big.NewInt(size1))
+	return rate1.Cmp(rate2) > 0
+}
+
+func (e *FeeEstimate) Output() string {
+	return fmt.Sprintf("Fee estimate (fee rates per %v bytes)\n"+
+		"  Recent blocks: %v, median of their lowest fee rates: %v\n"+
+		"  Pending txs: %v, fee rate to outbid the next block: %v\n"+
+		"  Suggested fee rate: %v, minimum fee: %v\n"+
+		"  Suggested fee of a %v bytes transaction: %v\n",
+		FeeRateBytes,
+		e.Blocks, e.RecentRate,
+		e.PendingTxs, e.PoolRate,
+		e.Rate, e.MinFee,
+		e.Size, e.Suggested)
+}
@@ -0,0 +1,206 @@
+package core
+
+import (
+	"fmt"
+	"github.com/XiaoYao-0/memory-blockchain/common"
+	"github.com/boltdb/bolt"
+)
+
+// InsertBlock add a sealed block mined locally or received from another node.
+// The block is stored on a side chain unless the chain ending with it has more total work than the main chain,
+// in that case the main chain is reorganized: blocks abandoned are reverted, blocks of the new branch are executed,
+// transactions of the abandoned blocks go back into Txs-Pool. Everything is written in one bolt transaction,
+// so nothing is changed if it fails. It returns whether the block becomes the tip
+func (bc *Blockchain) InsertBlock(block *Block) (bool, error) {
+	err := verifyBody(block, bc.Config)
+	if err != nil {
+		return false, fmt.Errorf("InsertBlock error: %v", err)
+	}
+	bc.chainMu.Lock()
+	defer bc.chainMu.Unlock()
+	var reverted, applied []*Block
+	err = bc.DB.Update(func(tx *bolt.Tx) error {
+		txError := bc.BlocksDB.AddBlock(tx, block)
+		if txError != nil {
+			return txError
+		}
+		tip, txError := getTip(tx)
+		if txError != nil {
+			return txError
+		}
+		tipWork, txError := getTotalWork(tx, tip)
+		if txError != nil {
+			return txError
+		}
+		work, txError := getTotalWork(tx, block.Hash)
+		if txError != nil {
+			return txError
+		}
+		// the first block seen wins when total works are equal
+		if work.Cmp(tipWork) <= 0 {
+			return nil
+		}
+		reverted, applied, txError = bc.reorg(tx, tip, block.Hash)
+		if txError != nil {
+			return txError
+		}
+		return bc.BlocksDB.SetTip(tx, block.Hash)
+	})
+	if err == ErrKnownBlock {
+		return false, err
+	}
+	if err != nil {
+		return false, fmt.Errorf("InsertBlock error: %v", err)
+	}
+	if len(applied) == 0 {
+		return false, nil
+	}
+	bc.setTip(block.Hash)
+	bc.updateTxsPool(reverted, applied)
+	if len(reverted) > 0 {
+		fmt.Printf("🔀 Chain reorganized: %v blocks reverted, %v blocks applied\n", len(reverted), len(applied))
+	}
+	return true, nil
+}
+
+// verifyBody check what can be checked without the chain state
+func verifyBody(block *Block, config *ChainConfig) error {
+	if len(block.Txs) == 0 || !block.Txs[0].IsCoinbase() {
+		return fmt.Errorf("block %v should start with a coinbase transaction", block.Hash.Hex(true))
+	}
+	err := block.CheckWeight(config)
+	if err != nil {
+		return err
+	}
+	seen := make(map[common.Hash]bool)
+	for _, tx := range block.Txs {
+		if seen[tx.Hash] {
+			return fmt.Errorf("transaction %v is packaged twice", tx.Hash.Hex(true))
+		}
+		seen[tx.Hash] = true
+		if tx == block.Txs[0] {
+			continue
+		}
+		err = tx.Verify()
+		if err != nil {
+			return err
+		}
+	}
+	if MerkleRoot(block.TxsHashes()) != block.Header.MerkleRoot {
+		return fmt.Errorf("Merkle root of transactions does not match %v", block.Header.MerkleRoot.Hex(true))
+	}
+	return nil
+}
+
+// reorg move the state in tx from the main chain ending with oldTip to the chain ending with newTip,
+// blocks are returned in the order they are reverted or applied
+func (bc *Blockchain) reorg(tx *bolt.Tx, oldTip, newTip common.Hash) ([]*Block, []*Block, error) {
+	var reverted, branch []*Block
+	oldBlock, err := getBlock(tx, oldTip)
+	if err != nil {
+		return nil, nil, err
+	}
+	newBlock, err := getBlock(tx, newTip)
+	if err != nil {
+		return nil, nil, err
+	}
+	for oldBlock.Hash != newBlock.Hash {
+		if oldBlock.Header.Height >= newBlock.Header.Height {
+			err = bc.revertBlock(tx, oldBlock)
+			if err != nil {
+				return nil, nil, err
+			}
+			reverted = append(reverted, oldBlock)
+			oldBlock, err = getBlock(tx, oldBlock.Header.PrevBlockHash)
+		} else {
+			branch = append(branch, newBlock)
+			newBlock, err = getBlock(tx, newBlock.Header.PrevBlockHash)
+		}
+		if err != nil {
+			return nil, nil, err
+		}
+	}
+	applied := make([]*Block, 0, len(branch))
+	for i := len(branch) - 1; i >= 0; i-- {
+		err = bc.applyBlock(tx, branch[i])
+		if err != nil {
+			return nil, nil, err
+		}
+		applied = append(applied, branch[i])
+	}
+	return reverted, applied, nil
+}
+
+func (bc *Blockchain) applyBlock(tx *bolt.Tx, block *Block) error {
+	err := bc.AccountsDB.ApplyBlock(tx, block, bc.Config.Rules(block.Header.Height), bc.Engine)
+	if err != nil {
+		return fmt.Errorf("block %v at height %v: %v", block.Hash.Hex(true), block.Header.Height, err)
+	}
+	for _, transaction := range block.Txs {
+		err = bc.TransactionsDB.AddTransaction(tx, transaction, block.Hash)
+		if err != nil {
+			return err
+		}
+	}
+	err = putSupply(tx, block, bc.Engine)
+	if err != nil {
+		return err
+	}
+	return bc.BlocksDB.IndexBlock(tx, block)
+}
+
+func (bc *Blockchain) revertBlock(tx *bolt.Tx, block *Block) error {
+	if block.Header.Height == 0 {
+		return fmt.Errorf("genesis block can not be reverted")
+	}
+	if _, ok := bc.Config.checkpointAt(block.Header.Height); ok {
+		return fmt.Errorf("block %v at height %v is a checkpoint and can not be reverted", block.Hash.Hex(true), block.Header.Height)
+	}
+	err := bc.AccountsDB.RevertBlock(tx, block.Hash)
+	if err != nil {
+		return err
+	}
+	for _, transaction := range block.Txs {
+		err = bc.TransactionsDB.RemoveTransaction(tx, transaction.Hash, block.Hash)
+		if err != nil {
+			return err
+		}
+	}
+	return bc.BlocksDB.UnindexBlock(tx, block)
+}
+
+// updateTxsPool remove txs packaged by applied from Txs-Pool and put back txs of reverted which are not packaged again,
+// txs whose nonce has been used on the new main chain can never be executed and are dropped, so are expired txs
+func (bc *Blockchain) updateTxsPool(reverted, applied []*Block) {
+	var packaged []common.Hash
+	isPackaged := make(map[common.Hash]bool)
+	for _, block := range applied {
+		for _, tx := range block.Txs {
+			packaged = append(packaged, tx.Hash)
+			isPackaged[tx.Hash] = true
+		}
+	}
+	bc.TxsPoolDB.RemoveTxs(packaged)
+	var orphaned []*Transaction
+	// reverted is from the tip down, put back txs of older blocks first
+	for i := len(reverted) - 1; i >= 0; i-- {
+		for _, tx := range reverted[i].Txs {
+			if isPackaged[tx.Hash] || tx.IsCoinbase() {
+				continue
+			}
+			orphaned = append(orphaned, tx)
+		}
+	}
+	if len(orphaned) > 0 {
+		bc.TxsPoolDB.LeftAddTxs(orphaned)
+	}
+	// txs expired on the new tip or whose nonce is used by the new main chain can never be packaged
+	tip, err := bc.BlocksDB.GetHeader(bc.CurrentTip())
+	if err != nil {
+		return
+	}
+	timestamp, err := bc.nextBlockTime(tip)
+	if err == nil {
+		_ = bc.dropUnpackableTxs(tip.Height+1, timestamp)
+	}
+}
@@ -0,0 +1,112 @@
+package core
+
+import (
+	"github.com/XiaoYao-0/memory-blockchain/common"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func testAccount(t *testing.T, bc *Blockchain, addr common.Address) *Account {
+	account, err := bc.AccountsDB.GetAccountOf(addr)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return account
+}
+
+func inTxsPool(bc *Blockchain, hash common.Hash) bool {
+	for _, tx := range bc.TxsPoolDB.GetAllTxs() {
+		if tx.Hash == hash {
+			return true
+		}
+	}
+	return false
+}
+
+func TestReorg(t *testing.T) {
+	tests := []struct {
+		name       string
+		checkpoint bool // the block of the main chain is a checkpoint
+		err        string
+	}{
+		{"onto a heavier branch", false, ""},
+		{"conflicting with a checkpoint", true, "conflicts with checkpoint"},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			// the main chain packages a tx of the first key at height 1,
+			// the branch of another node packages txs of the second key at heights 1 and 2
+			bc := newTestBlockchain(t, nil)
+			mainTx := newTestTx(t, bc, testKeys[0], 100, 0)
+			err := bc.SendTransaction(mainTx)
+			if err != nil {
+				t.Fatal(err)
+			}
+			mineTestBlock(t, bc)
+			mainTip := bc.CurrentTip()
+			if test.checkpoint {
+				bc.Config.Checkpoints = []Checkpoint{{Height: 1, Hash: mainTip.Hex(true)}}
+			}
+			mainAccounts := []*Account{testAccount(t, bc, testAddress(testKeys[0])), testAccount(t, bc, testMiner)}
+			other := newTestBlockchain(t, nil)
+			for i := 0; i < 2; i++ {
+				err = other.SendTransaction(newTestTx(t, other, testKeys[1], 100, 0))
+				if err != nil {
+					t.Fatal(err)
+				}
+				mineTestBlock(t, other)
+			}
+			branch := make([]*Block, 2)
+			for i := range branch {
+				branch[i], err = other.BlocksDB.GetBlockByHeight(int64(i + 1))
+				if err != nil {
+					t.Fatal(err)
+				}
+			}
+
+			var isTip bool
+			for i, block := range branch {
+				isTip, err = bc.InsertBlock(block)
+				if test.err != "" {
+					break
+				}
+				// the first block seen wins when total works are equal
+				if i == 0 && (err != nil || isTip) {
+					t.Fatalf("block of equal work should be stored on a side chain, tip: %v, error: %v", isTip, err)
+				}
+			}
+			if test.err != "" {
+				if err == nil || !strings.Contains(err.Error(), test.err) {
+					t.Fatalf("branch should be refused with %q, got %v", test.err, err)
+				}
+				if bc.CurrentTip() != mainTip {
+					t.Fatal("tip should not change")
+				}
+				accounts := []*Account{testAccount(t, bc, testAddress(testKeys[0])), testAccount(t, bc, testMiner)}
+				if !reflect.DeepEqual(accounts, mainAccounts) {
+					t.Fatalf("accounts are changed by a refused branch: %+v", accounts)
+				}
+				return
+			}
+			if err != nil || !isTip {
+				t.Fatalf("heavier branch should become the main chain, tip: %v, error: %v", isTip, err)
+			}
+			// state is undone to genesis and the branch is executed, as the other node did
+			for _, addr := range []common.Address{testAddress(testKeys[0]), testAddress(testKeys[1]), testMiner} {
+				if account, expected := testAccount(t, bc, addr), testAccount(t, other, addr); !reflect.DeepEqual(account, expected) {
+					t.Fatalf("account %v is %+v, want %+v", addr.Hex(true), account, expected)
+				}
+			}
+			if !inTxsPool(bc, mainTx.Hash) {
+				t.Fatal("tx of the reverted block should go back into Txs-Pool")
+			}
+			if _, err = bc.TransactionsDB.GetBlockHashOf(mainTx.Hash); err == nil {
+				t.Fatal("tx of the reverted block should not be recorded as packaged")
+			}
+			if _, err = bc.Verify(); err != nil {
+				t.Fatal(err)
+			}
+		})
+	}
+}
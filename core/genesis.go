@@ -0,0 +1,106 @@
+package core
+
+import (
+	"encoding/json"
+	"fmt"
+	"github.com/XiaoYao-0/memory-blockchain/common"
+	"io/ioutil"
+	"math"
+	"os"
+)
+
+const (
+	GenesisFile = "./genesis.json"
+)
+
+// Genesis describes the initial state and the consensus config of the chain
+//
+// genesis.json example, omitted config fields take the values of DefaultChainConfig:
+//
+//	{
+//	  "config": {
+//	    "targetBlockInterval": 30,
+//	    "forks": [
+//	      {"name": "cheaperMessages", "height": 1000, "bytesPerDataFee": 20}
+//	    ]
+//	  },
+//	  "alloc": {
+//	    "0x<address derived from your public key>": 10000000000
+//	  }
+//	}
+type Genesis struct {
+	Config *ChainConfig
+	Alloc  map[common.Address]int64
+}
+
+type genesisJSON struct {
+	Config *ChainConfig     `json:"config"`
+	Alloc  map[string]int64 `json:"alloc"`
+}
+
+// DefaultGenesis allocate initial funds to well-known addresses which have no private keys,
+// please provide your own genesis file to get spendable funds
+func DefaultGenesis() *Genesis {
+	genesis := &Genesis{
+		Config: DefaultChainConfig(),
+		Alloc:  make(map[common.Address]int64),
+	}
+	for _, hexS := range []string{
+		"0x0000000000000000000000000000000000000001",
+		"0x0000000000000000000000000000000000000002",
+		"0x0000000000000000000000000000000000000003",
+		"0x0000000000000000000000000000000000000004",
+		"0x0000000000000000000000000000000000000005",
+	} {
+		addr, _ := common.NewAddress(hexS)
+		genesis.Alloc[addr] = int64(math.Pow10(10))
+	}
+	return genesis
+}
+
+// TotalAlloc coins allocated to all accounts
+func (g *Genesis) TotalAlloc() int64 {
+	var total int64
+	for _, account := range initAccounts(g) {
+		total += account.Balance
+	}
+	return total
+}
+
+// LoadGenesis read genesis from file, DefaultGenesis is used if the file does not exist
+func LoadGenesis(path string) (*Genesis, error) {
+	data, err := ioutil.ReadFile(path)
+	if os.IsNotExist(err) {
+		return DefaultGenesis(), nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("LoadGenesis error: %v", err)
+	}
+	gj := genesisJSON{Config: DefaultChainConfig()}
+	err = json.Unmarshal(data, &gj)
+	if err != nil {
+		return nil, fmt.Errorf("LoadGenesis error: %v", err)
+	}
+	if gj.Config == nil {
+		gj.Config = DefaultChainConfig()
+	}
+	err = gj.Config.Validate()
+	if err != nil {
+		return nil, fmt.Errorf("LoadGenesis error: illegal config: %v", err)
+	}
+	genesis := &Genesis{
+		Config: gj.Config,
+		Alloc:  make(map[common.Address]int64),
+	}
+	for hexS, balance := range gj.Alloc {
+		addr, err := common.NewAddress(hexS)
+		if err != nil {
+			return nil, fmt.Errorf("LoadGenesis error: illegal address %v: %v", hexS, err)
+		}
+		if balance < 0 {
+			return nil, fmt.Errorf("LoadGenesis error: balance of %v should not be less than 0", hexS)
+		}
+		genesis.Alloc[addr] = balance
+	}
+	return genesis, nil
+}
@@ -0,0 +1,105 @@
+package core
+
+import (
+	"crypto/sha256"
+	"fmt"
+	"github.com/XiaoYao-0/memory-blockchain/common"
+)
+
+// Leaves and inner nodes are hashed with different prefixes so that an inner node can never be
+// presented as a leaf. A node without sibling is promoted to the next level unchanged.
+const (
+	merkleLeafPrefix byte = 0x00
+	merkleNodePrefix byte = 0x01
+)
+
+func merkleLeaf(hash common.Hash) common.Hash {
+	return sha256.Sum256(append([]byte{merkleLeafPrefix}, hash.Bytes()...))
+}
+
+func merkleNode(left, right common.Hash) common.Hash {
+	data := make([]byte, 0, 1+2*len(left))
+	data = append(data, merkleNodePrefix)
+	data = append(data, left.Bytes()...)
+	data = append(data, right.Bytes()...)
+	return sha256.Sum256(data)
+}
+
+// MerkleTree Levels[0] are the leaves and the last level is the root
+type MerkleTree struct {
+	Levels [][]common.Hash
+}
+
+func NewMerkleTree(hashes []common.Hash) *MerkleTree {
+	tree := &MerkleTree{}
+	if len(hashes) == 0 {
+		return tree
+	}
+	level := make([]common.Hash, len(hashes))
+	for i, hash := range hashes {
+		level[i] = merkleLeaf(hash)
+	}
+	tree.Levels = append(tree.Levels, level)
+	for len(level) > 1 {
+		next := make([]common.Hash, 0, (len(level)+1)/2)
+		for i := 0; i < len(level); i += 2 {
+			if i+1 == len(level) {
+				next = append(next, level[i])
+				continue
+			}
+			next = append(next, merkleNode(level[i], level[i+1]))
+		}
+		tree.Levels = append(tree.Levels, next)
+		level = next
+	}
+	return tree
+}
+
+// Root the root of an empty tree is the zero hash
+func (t *MerkleTree) Root() common.Hash {
+	if len(t.Levels) == 0 {
+		return common.Hash{}
+	}
+	return t.Levels[len(t.Levels)-1][0]
+}
+
+// MerkleStep a sibling on the path from a leaf to the root
+type MerkleStep struct {
+	Hash common.Hash
+	Left bool // sibling is the left child
+}
+
+// Path from the leaf at index to the root, levels where the node has no sibling are skipped
+func (t *MerkleTree) Path(index int) ([]MerkleStep, error) {
+	if len(t.Levels) == 0 || index < 0 || index >= len(t.Levels[0]) {
+		return nil, fmt.Errorf("Path error: index %v out of range", index)
+	}
+	var path []MerkleStep
+	for _, level := range t.Levels[:len(t.Levels)-1] {
+		if index%2 == 1 {
+			path = append(path, MerkleStep{Hash: level[index-1], Left: true})
+		} else if index+1 < len(level) {
+			path = append(path, MerkleStep{Hash: level[index+1], Left: false})
+		}
+		index /= 2
+	}
+	return path, nil
+}
+
+// MerkleRoot of hashes, the root of no hashes is the zero hash
+func MerkleRoot(hashes []common.Hash) common.Hash {
+	return NewMerkleTree(hashes).Root()
+}
+
+// MerkleRootFromPath compute the root from a leaf hash and its path
+func MerkleRootFromPath(hash common.Hash, path []MerkleStep) common.Hash {
+	node := merkleLeaf(hash)
+	for _, step := range path {
+		if step.Left {
+			node = merkleNode(step.Hash, node)
+		} else {
+			node = merkleNode(node, step.Hash)
+		}
+	}
+	return node
+}
@@ -0,0 +1,186 @@
+package core
+
+import (
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"github.com/XiaoYao-0/memory-blockchain/common"
+	"io"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+)
+
+const (
+	MaxOutputsOfTx = 256 // max number of outputs of a multi-transfer transaction
+)
+
+// TransferOutput coins and an optional message to one recipient of a MultiTransferPayload
+type TransferOutput struct {
+	To     common.Address
+	Amount int64
+	Data   []byte // no longer than MaxLengthOfData
+}
+
+// MultiTransferPayload transfer to every output, all or nothing. The fee is charged once on the total amount
+// and the total length of messages
+type MultiTransferPayload struct {
+	Outputs []TransferOutput
+}
+
+// NewMultiTransferTransaction a transaction paying outputs, fee and validUntil are as in NewTransaction
+func NewMultiTransferTransaction(rules *Rules, from common.Address, outputs []TransferOutput, fee, validUntil int64, nonce uint64) (*Transaction, error) {
+	return newTransaction(rules, from, nonce, fee, validUntil, &MultiTransferPayload{Outputs: outputs})
+}
+
+func (p *MultiTransferPayload) Type() TxType { return TxMultiTransfer }
+
+// amount the sum of outputs, it is only meaningful after verify
+func (p *MultiTransferPayload) amount() int64 {
+	var sum int64
+	for _, output := range p.Outputs {
+		sum += output.Amount
+	}
+	return sum
+}
+
+func (p *MultiTransferPayload) data() []byte {
+	var data []byte
+	for _, output := range p.Outputs {
+		data = append(data, output.Data...)
+	}
+	return data
+}
+
+func (p *MultiTransferPayload) encode() []byte {
+	e := &payloadEncoder{}
+	e.int64(int64(len(p.Outputs)))
+	for _, output := range p.Outputs {
+		e.address(output.To)
+		e.int64(output.Amount)
+		e.bytes(output.Data)
+	}
+	return e.buf.Bytes()
+}
+
+func (p *MultiTransferPayload) decode(d *payloadDecoder) {
+	count := d.int64()
+	if count < 0 || count > MaxOutputsOfTx {
+		d.err = fmt.Errorf("illegal number of outputs %v", count)
+		return
+	}
+	p.Outputs = make([]TransferOutput, count)
+	for i := range p.Outputs {
+		p.Outputs[i].To = d.address()
+		p.Outputs[i].Amount = d.int64()
+		p.Outputs[i].Data = d.bytes()
+	}
+}
+
+func (p *MultiTransferPayload) verify(rules *Rules) error {
+	if len(p.Outputs) == 0 || len(p.Outputs) > MaxOutputsOfTx {
+		return fmt.Errorf("number of outputs should be between 1 and %v", MaxOutputsOfTx)
+	}
+	var sum int64
+	for i, output := range p.Outputs {
+		if output.Amount < 0 {
+			return fmt.Errorf("amount of output %v should not be less than 0", i)
+		}
+		if sum+output.Amount < sum {
+			return fmt.Errorf("integer overflow: total amount of outputs is out of int64 range")
+		}
+		sum += output.Amount
+		err := rules.verifyDataLength(output.Data)
+		if err != nil {
+			return fmt.Errorf("output %v: %v", i, err)
+		}
+	}
+	return nil
+}
+
+// exec credit every output, the state is discarded by the caller if one of them fails
+func (p *MultiTransferPayload) exec(state State) error {
+	for i, output := range p.Outputs {
+		err := credit(state, output.To, output.Amount, output.Data)
+		if err != nil {
+			return fmt.Errorf("output %v: %v", i, err)
+		}
+	}
+	return nil
+}
+
+func (p *MultiTransferPayload) output() string {
+	output := fmt.Sprintf("  Outputs: %v, total amount %v\n", len(p.Outputs), p.amount())
+	for _, o := range p.Outputs {
+		output += fmt.Sprintf("    To: %v, Amount: %v", o.To.Hex(true), o.Amount)
+		if len(o.Data) != 0 {
+			output += fmt.Sprintf(", Data: %s", o.Data)
+		}
+		output += "\n"
+	}
+	return output
+}
+
+type transferOutputJSON struct {
+	To      string `json:"to"`
+	Amount  int64  `json:"amount"`
+	Message string `json:"message"`
+}
+
+// ReadTransferOutputs read outputs from a JSON file of [{"to": "0x...", "amount": 1, "message": "..."}, ...]
+// if the name ends with .json, otherwise from a CSV file of lines "to,amount[,message]" with an optional header line
+func ReadTransferOutputs(path string) ([]TransferOutput, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, fmt.Errorf("ReadTransferOutputs error: %v", err)
+	}
+	defer file.Close()
+	var records []transferOutputJSON
+	if strings.EqualFold(filepath.Ext(path), ".json") {
+		err = json.NewDecoder(file).Decode(&records)
+	} else {
+		records, err = readTransferOutputsCSV(file)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("ReadTransferOutputs error: %v", err)
+	}
+	outputs := make([]TransferOutput, len(records))
+	for i, record := range records {
+		to, err := common.NewAddress(record.To)
+		if err != nil {
+			return nil, fmt.Errorf("ReadTransferOutputs error: output %v: %v", i+1, err)
+		}
+		outputs[i] = TransferOutput{To: to, Amount: record.Amount, Data: []byte(record.Message)}
+	}
+	return outputs, nil
+}
+
+func readTransferOutputsCSV(r io.Reader) ([]transferOutputJSON, error) {
+	reader := csv.NewReader(r)
+	reader.FieldsPerRecord = -1
+	reader.TrimLeadingSpace = true
+	lines, err := reader.ReadAll()
+	if err != nil {
+		return nil, err
+	}
+	var records []transferOutputJSON
+	for i, line := range lines {
+		if i == 0 && len(line) > 0 && strings.EqualFold(line[0], "to") {
+			continue
+		}
+		if len(line) < 2 || len(line) > 3 {
+			return nil, fmt.Errorf("line %v should be to,amount[,message]", i+1)
+		}
+		amount, err := strconv.ParseInt(line[1], 10, 64)
+		if err != nil {
+			return nil, fmt.Errorf("line %v: illegal amount %q", i+1, line[1])
+		}
+		record := transferOutputJSON{To: line[0], Amount: amount}
+		if len(line) == 3 {
+			record.Message = line[2]
+		}
+		records = append(records, record)
+	}
+	return records, nil
+}
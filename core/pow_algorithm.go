@@ -0,0 +1,75 @@
+package core
+
+import (
+	"context"
+	"crypto/sha256"
+	"fmt"
+	"github.com/XiaoYao-0/memory-blockchain/common"
+	"golang.org/x/crypto/scrypt"
+	"time"
+)
+
+const (
+	PowSHA256 = "sha256"
+	PowScrypt = "scrypt" // memory-hard, every hash takes 128*ScryptN*ScryptR bytes of memory
+)
+
+// PowAlgorithm the hash function of proof-of-work, the identity of a block is still the SHA256 header hash
+type PowAlgorithm struct {
+	Name string
+	hash func(data []byte) (common.Hash, error)
+	// a mining worker checks if mining is aborted every hashesBetweenChecks hashes, so that a slow hash function
+	// does not delay the abort for long
+	hashesBetweenChecks uint64
+}
+
+func (a *PowAlgorithm) Hash(data []byte) (common.Hash, error) {
+	return a.hash(data)
+}
+
+func NewSHA256Pow() *PowAlgorithm {
+	return &PowAlgorithm{
+		Name: PowSHA256,
+		hash: func(data []byte) (common.Hash, error) {
+			return sha256.Sum256(data), nil
+		},
+		// checking ctx on every hash is too expensive
+		hashesBetweenChecks: 1 << 10,
+	}
+}
+
+// NewScryptPow the header data is used as both the password and the salt
+func NewScryptPow(n, r, p int) *PowAlgorithm {
+	return &PowAlgorithm{
+		Name: fmt.Sprintf("%v(N=%v,r=%v,p=%v)", PowScrypt, n, r, p),
+		hash: func(data []byte) (common.Hash, error) {
+			key, err := scrypt.Key(data, data, n, r, p, len(common.Hash{}))
+			if err != nil {
+				return common.Hash{}, err
+			}
+			var hash common.Hash
+			copy(hash[:], key)
+			return hash, nil
+		},
+		// a single hash may take seconds with the largest ScryptN
+		hashesBetweenChecks: 1,
+	}
+}
+
+// PowAlgorithms all algorithms with the parameters of config
+func PowAlgorithms(config *ChainConfig) []*PowAlgorithm {
+	return []*PowAlgorithm{
+		NewSHA256Pow(),
+		NewScryptPow(int(config.ScryptN), int(config.ScryptR), int(config.ScryptP)),
+	}
+}
+
+// BenchmarkPow the hash rate of algorithm with workers goroutines searching for duration
+func BenchmarkPow(algorithm *PowAlgorithm, workers int, duration time.Duration) float64 {
+	// no hash meets the target of 256 bits in practice
+	pow := NewProofOfWork(&BlockHeader{Version: BlockVersion, Bits: 256}, algorithm)
+	ctx, cancel := context.WithTimeout(context.Background(), duration)
+	defer cancel()
+	_, _, _ = pow.Run(ctx, workers)
+	return pow.HashRate()
+}
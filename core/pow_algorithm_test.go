@@ -0,0 +1,26 @@
+package core
+
+import (
+	"testing"
+	"time"
+)
+
+func TestBenchmarkPowStopsInTime(t *testing.T) {
+	tests := []struct {
+		name      string
+		algorithm *PowAlgorithm
+	}{
+		{"sha256", NewSHA256Pow()},
+		// about 16MB and tens of milliseconds per hash, 1024 hashes between checks would take a minute
+		{"scrypt", NewScryptPow(1<<14, 8, 1)},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			start := time.Now()
+			BenchmarkPow(test.algorithm, 2, 10*time.Millisecond)
+			if elapsed := time.Since(start); elapsed > 2*time.Second {
+				t.Fatalf("mining aborted %v after the deadline", elapsed)
+			}
+		})
+	}
+}
@@ -0,0 +1,130 @@
+package core
+
+import (
+	"context"
+	"crypto/ecdsa"
+	"crypto/rand"
+	"fmt"
+	"github.com/XiaoYao-0/memory-blockchain/common"
+	"sync"
+)
+
+// PoAEngine a fixed set of signers take turns sealing blocks, the block at height h is sealed by
+// Signers[h % len(Signers)]. Seal = public key (65 bytes) + ASN.1 ECDSA signature of SealHash.
+// No coins are minted, the signer only collects fees.
+type PoAEngine struct {
+	config  *ChainConfig
+	signers []common.Address
+	mu      sync.Mutex
+	key     *ecdsa.PrivateKey // key of the local signer
+}
+
+const (
+	publicKeyLength = 65 // length of an uncompressed P-256 public key
+)
+
+func NewPoAEngine(config *ChainConfig) (*PoAEngine, error) {
+	if len(config.Signers) == 0 {
+		return nil, fmt.Errorf("NewPoAEngine error: no signer is configured")
+	}
+	signers := make([]common.Address, len(config.Signers))
+	for i, hexS := range config.Signers {
+		signer, err := common.NewAddress(hexS)
+		if err != nil {
+			return nil, fmt.Errorf("NewPoAEngine error: illegal signer %v: %v", hexS, err)
+		}
+		signers[i] = signer
+	}
+	return &PoAEngine{config: config, signers: signers}, nil
+}
+
+// Authorize set the key used to seal blocks, it should belong to one of the signers
+func (e *PoAEngine) Authorize(key *ecdsa.PrivateKey) error {
+	addr := common.PubKeyToAddress(&key.PublicKey)
+	if !e.IsSigner(addr) {
+		return fmt.Errorf("Authorize error: %v is not a signer", addr.Hex(true))
+	}
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	e.key = key
+	return nil
+}
+
+func (e *PoAEngine) IsSigner(addr common.Address) bool {
+	for _, signer := range e.signers {
+		if signer == addr {
+			return true
+		}
+	}
+	return false
+}
+
+// SignerAt the signer in turn at height
+func (e *PoAEngine) SignerAt(height int64) common.Address {
+	return e.signers[height%int64(len(e.signers))]
+}
+
+func (e *PoAEngine) Prepare(chain HeaderReader, header, parent *BlockHeader) error {
+	header.Bits = 0
+	return nil
+}
+
+func (e *PoAEngine) Seal(ctx context.Context, header *BlockHeader) error {
+	if ctx.Err() != nil {
+		return ErrMiningAborted
+	}
+	e.mu.Lock()
+	key := e.key
+	e.mu.Unlock()
+	if key == nil {
+		return fmt.Errorf("Seal error: no signer key is authorized")
+	}
+	signer := common.PubKeyToAddress(&key.PublicKey)
+	if signer != e.SignerAt(header.Height) {
+		return fmt.Errorf("Seal error: it is the turn of %v at height %v", e.SignerAt(header.Height).Hex(true), header.Height)
+	}
+	if header.Miner != signer {
+		return fmt.Errorf("Seal error: miner %v is not the signer %v", header.Miner.Hex(true), signer.Hex(true))
+	}
+	header.Seal = nil
+	hash := header.SealHash()
+	signature, err := ecdsa.SignASN1(rand.Reader, key, hash.Bytes())
+	if err != nil {
+		return fmt.Errorf("Seal error: %v", err)
+	}
+	header.Seal = append(common.PublicKeyBytes(&key.PublicKey), signature...)
+	return nil
+}
+
+func (e *PoAEngine) VerifyHeader(chain HeaderReader, header, parent *BlockHeader) error {
+	if header.Bits != 0 {
+		return fmt.Errorf("VerifyHeader error: bits of a PoA block should be 0")
+	}
+	if len(header.Seal) <= publicKeyLength {
+		return fmt.Errorf("VerifyHeader error: block is not sealed")
+	}
+	pub, err := common.PublicKeyFromBytes(header.Seal[:publicKeyLength])
+	if err != nil {
+		return fmt.Errorf("VerifyHeader error: %v", err)
+	}
+	signer := common.PubKeyToAddress(pub)
+	if signer != e.SignerAt(header.Height) {
+		return fmt.Errorf("VerifyHeader error: block is sealed by %v but it is the turn of %v",
+			signer.Hex(true), e.SignerAt(header.Height).Hex(true))
+	}
+	if header.Miner != signer {
+		return fmt.Errorf("VerifyHeader error: miner %v is not the signer %v", header.Miner.Hex(true), signer.Hex(true))
+	}
+	hash := header.SealHash()
+	if !ecdsa.VerifyASN1(pub, hash.Bytes(), header.Seal[publicKeyLength:]) {
+		return fmt.Errorf("VerifyHeader error: invalid seal")
+	}
+	return nil
+}
+
+// CalculateReward signers are not paid any subsidy, only fees which are not burned
+func (e *PoAEngine) CalculateReward(header *BlockHeader, fees int64) Reward {
+	reward := e.config.CalcReward(header.Height, fees)
+	reward.Subsidy = 0
+	return reward
+}
@@ -1,84 +1,195 @@
 package core
 
 import (
-	"bytes"
-	"crypto/sha256"
+	"context"
+	"errors"
 	"fmt"
 	"github.com/XiaoYao-0/memory-blockchain/common"
-	"math"
 	"math/big"
 	"strconv"
+	"sync"
+	"sync/atomic"
+	"time"
 )
 
-var targetBits = 24 // 挖矿难度
+var ErrMiningAborted = errors.New("mining is aborted")
 
 type ProofOfWork struct {
-	block  *Block
-	target *big.Int
+	header    *BlockHeader
+	algorithm *PowAlgorithm
+	target    *big.Int
+	hashes    uint64        // hashes computed by the last Run
+	duration  time.Duration // duration of the last Run
 }
 
-func NewProofOfWork(b *Block) *ProofOfWork {
+// NewProofOfWork only the header is hashed by algorithm, the target is 2^(256-header.Bits)
+func NewProofOfWork(h *BlockHeader, algorithm *PowAlgorithm) *ProofOfWork {
 	target := big.NewInt(1)
-	target.Lsh(target, uint(256-targetBits))
+	if h.Bits >= 0 && h.Bits <= 256 {
+		target.Lsh(target, uint(256-h.Bits))
+	} else {
+		// illegal difficulty, no hash can meet it
+		target.SetInt64(0)
+	}
 
-	pow := &ProofOfWork{b, target}
+	pow := &ProofOfWork{header: h, algorithm: algorithm, target: target}
 
 	return pow
 }
 
 func (pow *ProofOfWork) prepareData(nonce int64) []byte {
-	txsHash := make([][]byte, len(pow.block.Txs))
-	for i, tx := range pow.block.Txs {
-		txsHash[i] = tx.Hash.Bytes()
-	}
-	data := bytes.Join(
-		[][]byte{
-			pow.block.PrevBlockHash.Bytes(),
-			IntToHex(pow.block.Timestamp),
-			IntToHex(int64(targetBits)),
-			IntToHex(nonce),
-			pow.block.Miner.Bytes(),
-		},
-		bytes.Join(txsHash, []byte{}),
-	)
-	return data
+	return pow.header.bytesWithNonce(nonce)
 }
 
-func (pow *ProofOfWork) Run() (int64, common.Hash) {
-	var hashInt big.Int
-	var hash [32]byte
-	nonce := int64(0)
-	maxNonce := math.MaxInt64
-	fmt.Printf("Mining block...\n")
-	for nonce < int64(maxNonce) {
-		data := pow.prepareData(nonce)
-		hash = sha256.Sum256(data)
-		hashInt.SetBytes(hash[:])
-
-		if hashInt.Cmp(pow.target) == -1 {
-			fmt.Printf("\r%x", hash)
-			break
-		} else {
-			nonce++
-		}
-	}
-	fmt.Print("\n\n")
-
-	return nonce, hash
+// Run search the nonce space with workers goroutines, worker i tries nonces i, i+workers, i+2*workers...
+// It returns ErrMiningAborted as soon as ctx is done.
+func (pow *ProofOfWork) Run(ctx context.Context, workers int) (int64, common.Hash, error) {
+	if workers < 1 {
+		workers = 1
+	}
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+	type result struct {
+		nonce int64
+		hash  common.Hash
+	}
+	found := make(chan result, workers)
+	failed := make(chan error, workers)
+	var hashes uint64
+	var wg sync.WaitGroup
+	start := time.Now()
+	fmt.Printf("Mining block with %v workers using %v...\n", workers, pow.algorithm.Name)
+	for i := 0; i < workers; i++ {
+		wg.Add(1)
+		go func(nonce int64) {
+			defer wg.Done()
+			var hashInt big.Int
+			var count uint64
+			defer func() {
+				atomic.AddUint64(&hashes, count)
+			}()
+			for ; nonce >= 0; nonce += int64(workers) {
+				if count%pow.algorithm.hashesBetweenChecks == 0 && ctx.Err() != nil {
+					return
+				}
+				hash, err := pow.algorithm.Hash(pow.prepareData(nonce))
+				if err != nil {
+					failed <- err
+					cancel()
+					return
+				}
+				count++
+				hashInt.SetBytes(hash[:])
+				if hashInt.Cmp(pow.target) == -1 {
+					found <- result{nonce, hash}
+					cancel()
+					return
+				}
+			}
+		}(int64(i))
+	}
+	wg.Wait()
+	pow.hashes = hashes
+	pow.duration = time.Since(start)
+	fmt.Printf("Hash rate: %.0f H/s\n", pow.HashRate())
+	select {
+	case r := <-found:
+		fmt.Printf("%x\n\n", r.hash)
+		return r.nonce, r.hash, nil
+	case err := <-failed:
+		return 0, common.Hash{}, fmt.Errorf("Run error: %v", err)
+	default:
+		return 0, common.Hash{}, ErrMiningAborted
+	}
+}
+
+// HashRate hashes per second of the last Run
+func (pow *ProofOfWork) HashRate() float64 {
+	if pow.duration <= 0 {
+		return 0
+	}
+	return float64(pow.hashes) / pow.duration.Seconds()
 }
 
-func (pow *ProofOfWork) Validate() bool {
+// Validate check the hash of the header meets its target and its difficulty is expectedBits
+func (pow *ProofOfWork) Validate(expectedBits int64) error {
+	if pow.header.Bits != expectedBits {
+		return fmt.Errorf("Validate error: bits=%v, expected %v", pow.header.Bits, expectedBits)
+	}
 	var hashInt big.Int
 
-	data := pow.prepareData(pow.block.Nonce)
-	hash := sha256.Sum256(data)
+	data := pow.prepareData(pow.header.Nonce)
+	hash, err := pow.algorithm.Hash(data)
+	if err != nil {
+		return fmt.Errorf("Validate error: %v", err)
+	}
 	hashInt.SetBytes(hash[:])
 
-	isValid := hashInt.Cmp(pow.target) == -1
-
-	return isValid
+	if hashInt.Cmp(pow.target) != -1 {
+		return fmt.Errorf("Validate error: hash %x does not meet the target of %v bits", hash, pow.header.Bits)
+	}
+	return nil
 }
 
 func IntToHex(n int64) []byte {
 	return []byte(strconv.FormatInt(n, 16))
 }
+
+// PowEngine the proof-of-work ConsensusEngine with difficulty retargeting
+type PowEngine struct {
+	config  *ChainConfig
+	threads int32
+}
+
+func NewPowEngine(config *ChainConfig) *PowEngine {
+	return &PowEngine{
+		config:  config,
+		threads: 1,
+	}
+}
+
+// SetThreads set the number of goroutines doing proof-of-work in Seal
+func (e *PowEngine) SetThreads(threads int) {
+	if threads < 1 {
+		threads = 1
+	}
+	atomic.StoreInt32(&e.threads, int32(threads))
+}
+
+func (e *PowEngine) Prepare(chain HeaderReader, header, parent *BlockHeader) error {
+	bits, err := calcNextBits(chain, e.config, parent)
+	if err != nil {
+		return fmt.Errorf("Prepare error: %v", err)
+	}
+	header.Bits = bits
+	return nil
+}
+
+func (e *PowEngine) Seal(ctx context.Context, header *BlockHeader) error {
+	header.Seal = nil
+	nonce, _, err := NewProofOfWork(header, e.config.Rules(header.Height).NewPowAlgorithm()).Run(ctx, int(atomic.LoadInt32(&e.threads)))
+	if err != nil {
+		return err
+	}
+	header.Nonce = nonce
+	return nil
+}
+
+func (e *PowEngine) VerifyHeader(chain HeaderReader, header, parent *BlockHeader) error {
+	if len(header.Seal) != 0 {
+		return fmt.Errorf("VerifyHeader error: proof-of-work block should have no seal")
+	}
+	bits, err := calcNextBits(chain, e.config, parent)
+	if err != nil {
+		return fmt.Errorf("VerifyHeader error: %v", err)
+	}
+	err = NewProofOfWork(header, e.config.Rules(header.Height).NewPowAlgorithm()).Validate(bits)
+	if err != nil {
+		return fmt.Errorf("VerifyHeader error: %v", err)
+	}
+	return nil
+}
+
+func (e *PowEngine) CalculateReward(header *BlockHeader, fees int64) Reward {
+	return e.config.CalcReward(header.Height, fees)
+}
@@ -0,0 +1,96 @@
+package core
+
+import (
+	"fmt"
+	"github.com/XiaoYao-0/memory-blockchain/common"
+)
+
+// NewReplacementTx a copy of the pending tx of hash paying fee, fee 0 outbids the pending tx and the fee estimate.
+// It should be signed by the sender and sent by SendTransaction to replace the pending one
+func (bc *Blockchain) NewReplacementTx(hash common.Hash, fee int64) (*Transaction, error) {
+	pending, err := bc.getPendingTx(hash)
+	if err != nil {
+		return nil, fmt.Errorf("NewReplacementTx error: %v", err)
+	}
+	fee, err = bc.replacementFee(pending, fee)
+	if err != nil {
+		return nil, fmt.Errorf("NewReplacementTx error: %v", err)
+	}
+	tx := &Transaction{
+		Version:    pending.Version,
+		From:       pending.From,
+		Fee:        fee,
+		Nonce:      pending.Nonce,
+		ValidUntil: pending.ValidUntil,
+		Payload:    pending.Payload,
+	}
+	tx.Hash = tx.CalcHash()
+	return tx, nil
+}
+
+// NewCancellationTx a zero-value self-transfer replacing the pending tx of hash, see NewReplacementTx
+func (bc *Blockchain) NewCancellationTx(hash common.Hash, fee int64) (*Transaction, error) {
+	pending, err := bc.getPendingTx(hash)
+	if err != nil {
+		return nil, fmt.Errorf("NewCancellationTx error: %v", err)
+	}
+	fee, err = bc.replacementFee(pending, fee)
+	if err != nil {
+		return nil, fmt.Errorf("NewCancellationTx error: %v", err)
+	}
+	rules, err := bc.NextRules()
+	if err != nil {
+		return nil, fmt.Errorf("NewCancellationTx error: %v", err)
+	}
+	tx, err := NewTransaction(rules, pending.From, pending.From, "", 0, fee, 0, pending.Nonce)
+	if err != nil {
+		return nil, fmt.Errorf("NewCancellationTx error: %v", err)
+	}
+	return tx, nil
+}
+
+func (bc *Blockchain) getPendingTx(hash common.Hash) (*Transaction, error) {
+	for _, tx := range bc.TxsPoolDB.GetAllTxs() {
+		if tx.Hash == hash {
+			return tx, nil
+		}
+	}
+	return nil, fmt.Errorf("transaction %v is not in Txs-Pool", hash.Hex(true))
+}
+
+// replacementFee fee if it is set, otherwise the higher one of the fee suggested for the size of pending
+// and a fee outbidding pending
+func (bc *Blockchain) replacementFee(pending *Transaction, fee int64) (int64, error) {
+	if fee < 0 {
+		return 0, fmt.Errorf("fee should not be less than 0")
+	}
+	if fee != 0 {
+		if fee <= pending.Fee {
+			return 0, fmt.Errorf("fee should be more than %v of the pending transaction", pending.Fee)
+		}
+		return fee, nil
+	}
+	estimate, err := bc.EstimateFee(DefaultFeeEstimateBlocks, pending.Size())
+	if err != nil {
+		return 0, err
+	}
+	fee = pending.Fee + 1
+	if estimate.Suggested > fee {
+		fee = estimate.Suggested
+	}
+	return fee, nil
+}
+
+// IsCancellation whether tx is a zero-value self-transfer, which only uses up the nonce of the sender
+func (tx *Transaction) IsCancellation() bool {
+	transfer, ok := tx.Payload.(*TransferPayload)
+	return ok && transfer.To == tx.From && transfer.Amount == 0
+}
+
+// replacedReason why a pending tx is dropped for tx
+func replacedReason(tx *Transaction) string {
+	if tx.IsCancellation() {
+		return fmt.Sprintf("cancelled by transaction %v paying fee %v", tx.Hash.Hex(true), tx.Fee)
+	}
+	return fmt.Sprintf("replaced by transaction %v paying fee %v", tx.Hash.Hex(true), tx.Fee)
+}
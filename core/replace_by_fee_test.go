@@ -0,0 +1,97 @@
+package core
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestReplaceByFee(t *testing.T) {
+	bc := newTestBlockchain(t, nil)
+	key := testKeys[0]
+	pending := newTestTx(t, bc, key, 100, 50)
+	err := bc.SendTransaction(pending)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var replaced []*Transaction
+
+	// every case replaces the tx left pending by the previous one
+	tests := []struct {
+		name   string
+		newTx  func() (*Transaction, error)
+		err    string
+		reason string // reason of the replaced tx
+	}{
+		{"same fee", func() (*Transaction, error) {
+			return newTestTxWithNonce(t, bc, key, 200, 50, pending.Nonce), nil
+		}, "a replacement should pay a fee more than 50", ""},
+		{"lower fee", func() (*Transaction, error) {
+			return newTestTxWithNonce(t, bc, key, 200, 40, pending.Nonce), nil
+		}, "a replacement should pay a fee more than 50", ""},
+		{"bumped to the same fee", func() (*Transaction, error) {
+			return bc.NewReplacementTx(pending.Hash, 50)
+		}, "fee should be more than 50", ""},
+		{"higher fee", func() (*Transaction, error) {
+			return newTestTxWithNonce(t, bc, key, 200, 60, pending.Nonce), nil
+		}, "", "replaced by transaction"},
+		{"bumped fee", func() (*Transaction, error) {
+			return bc.NewReplacementTx(pending.Hash, 0)
+		}, "", "replaced by transaction"},
+		{"cancellation", func() (*Transaction, error) {
+			return bc.NewCancellationTx(pending.Hash, 0)
+		}, "", "cancelled by transaction"},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			tx, err := test.newTx()
+			if err == nil {
+				err = tx.Sign(key)
+				if err != nil {
+					t.Fatal(err)
+				}
+				err = bc.SendTransaction(tx)
+			}
+			if test.err != "" {
+				if err == nil || !strings.Contains(err.Error(), test.err) {
+					t.Fatalf("replacement should be refused with %q, got %v", test.err, err)
+				}
+				if !inTxsPool(bc, pending.Hash) {
+					t.Fatal("pending tx should be kept")
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			if inTxsPool(bc, pending.Hash) || !inTxsPool(bc, tx.Hash) {
+				t.Fatal("pending tx should be replaced in Txs-Pool")
+			}
+			dropped, err := bc.TxsPoolDB.GetDroppedTx(pending.Hash)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !strings.Contains(dropped.Reason, test.reason) || !strings.Contains(dropped.Reason, tx.Hash.Hex(true)) {
+				t.Fatalf("unexpected reason %q", dropped.Reason)
+			}
+			replaced = append(replaced, pending)
+			pending = tx
+		})
+	}
+
+	// only the cancellation is packaged, the sender pays its fee and nothing else
+	if !pending.IsCancellation() {
+		t.Fatal("last pending tx should be a cancellation")
+	}
+	before := testAccount(t, bc, testAddress(key))
+	mineTestBlock(t, bc)
+	after := testAccount(t, bc, testAddress(key))
+	if after.Balance != before.Balance-pending.Fee || after.Nonce != pending.Nonce+1 {
+		t.Fatalf("account is %+v after the cancellation, want balance %v and nonce %v",
+			after, before.Balance-pending.Fee, pending.Nonce+1)
+	}
+	for _, tx := range replaced {
+		if _, err = bc.TransactionsDB.GetBlockHashOf(tx.Hash); err == nil {
+			t.Fatalf("replaced tx %v should not be packaged", tx.Hash.Hex(true))
+		}
+	}
+}
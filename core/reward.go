@@ -0,0 +1,80 @@
+package core
+
+import (
+	"math"
+)
+
+// Reward how the coins of a block are distributed
+type Reward struct {
+	Subsidy int64 // coins newly minted to the miner
+	Fees    int64 // fees paid to the miner
+	Burned  int64 // fees burned instead of being paid to the miner
+}
+
+// Total the award to the miner
+func (r *Reward) Total() int64 {
+	return r.Subsidy + r.Fees
+}
+
+// Subsidy the coins minted by the block at height, halved every HalvingInterval blocks and
+// stopped once the scheduled subsidies reach MaxSupply
+func (c *ChainConfig) Subsidy(height int64) int64 {
+	if height <= 0 {
+		return 0
+	}
+	subsidy := c.InitialSubsidy
+	if c.HalvingInterval > 0 {
+		halvings := (height - 1) / c.HalvingInterval
+		if halvings >= 63 {
+			return 0
+		}
+		subsidy >>= uint(halvings)
+	}
+	if c.MaxSupply > 0 {
+		left := c.MaxSupply - c.scheduledSubsidies(height-1)
+		if left <= 0 {
+			return 0
+		}
+		if subsidy > left {
+			subsidy = left
+		}
+	}
+	return subsidy
+}
+
+// scheduledSubsidies the sum of subsidies of blocks from height 1 to height without the MaxSupply limit,
+// it saturates at math.MaxInt64
+func (c *ChainConfig) scheduledSubsidies(height int64) int64 {
+	var total int64
+	remaining := height
+	interval := c.HalvingInterval
+	if interval <= 0 {
+		interval = remaining
+	}
+	for halvings := uint(0); remaining > 0 && halvings < 63; halvings++ {
+		subsidy := c.InitialSubsidy >> halvings
+		if subsidy == 0 {
+			break
+		}
+		blocks := remaining
+		if blocks > interval {
+			blocks = interval
+		}
+		if subsidy > (math.MaxInt64-total)/blocks {
+			return math.MaxInt64
+		}
+		total += subsidy * blocks
+		remaining -= blocks
+	}
+	return total
+}
+
+// CalcReward split fees of the block at height between the miner and burning, the subsidy is minted by the schedule
+func (c *ChainConfig) CalcReward(height int64, fees int64) Reward {
+	burned := fees/100*c.FeeBurnPercent + fees%100*c.FeeBurnPercent/100
+	return Reward{
+		Subsidy: c.Subsidy(height),
+		Fees:    fees - burned,
+		Burned:  burned,
+	}
+}
@@ -0,0 +1,100 @@
+package core
+
+import (
+	"fmt"
+	"github.com/XiaoYao-0/memory-blockchain/common"
+	"github.com/boltdb/bolt"
+	"sort"
+)
+
+// RewindTo undo the blocks of the main chain above height with their undo journals, so the block at height
+// becomes the tip, and their transactions go back into Txs-Pool. The undone blocks and the stored blocks
+// descending from them are marked invalid in the same bolt transaction, so no block can extend them and the chain
+// never reorganizes back onto them. Blocks of checkpoints can not be undone. It returns the number of undone blocks
+func (bc *Blockchain) RewindTo(height int64) (int, error) {
+	bc.chainMu.Lock()
+	defer bc.chainMu.Unlock()
+	var reverted []*Block
+	var newTip common.Hash
+	err := bc.DB.Update(func(tx *bolt.Tx) error {
+		tip, txError := getTip(tx)
+		if txError != nil {
+			return txError
+		}
+		tipHeader, txError := getHeader(tx, tip)
+		if txError != nil {
+			return txError
+		}
+		if height < 0 || height > tipHeader.Height {
+			return fmt.Errorf("height should be between 0 and the height of the tip %v", tipHeader.Height)
+		}
+		newTip, txError = getHashByHeight(tx, height)
+		if txError != nil {
+			return txError
+		}
+		reverted, _, txError = bc.reorg(tx, tip, newTip)
+		if txError != nil {
+			return txError
+		}
+		txError = invalidateBlocks(tx, reverted, height)
+		if txError != nil {
+			return txError
+		}
+		return bc.BlocksDB.SetTip(tx, newTip)
+	})
+	if err != nil {
+		return 0, fmt.Errorf("RewindTo error: %v", err)
+	}
+	if len(reverted) == 0 {
+		return 0, nil
+	}
+	bc.setTip(newTip)
+	bc.updateTxsPool(reverted, nil)
+	return len(reverted), nil
+}
+
+// invalidateBlocks mark blocks invalid with every stored block above height descending from one of them
+func invalidateBlocks(tx *bolt.Tx, blocks []*Block, height int64) error {
+	b := tx.Bucket([]byte(InvalidBlocksBucket))
+	hb := tx.Bucket([]byte(HeadersBucket))
+	if b == nil || hb == nil {
+		return fmt.Errorf("bucket %v or %v do not exist", InvalidBlocksBucket, HeadersBucket)
+	}
+	invalid := make(map[common.Hash]bool)
+	for _, block := range blocks {
+		invalid[block.Hash] = true
+	}
+	// a block is invalid if its parent is, parents are visited first in order of heights
+	var headers []*BlockHeader
+	err := hb.ForEach(func(k, v []byte) error {
+		header, err := DeserializeBlockHeader(v)
+		if err != nil {
+			return err
+		}
+		if header.Height > height {
+			headers = append(headers, header)
+		}
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+	sort.Slice(headers, func(i, j int) bool { return headers[i].Height < headers[j].Height })
+	for _, header := range headers {
+		if invalid[header.PrevBlockHash] {
+			invalid[header.Hash()] = true
+		}
+	}
+	for hash := range invalid {
+		err = b.Put(hash.Serialize(), []byte{})
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func isInvalidBlock(tx *bolt.Tx, hash common.Hash) bool {
+	b := tx.Bucket([]byte(InvalidBlocksBucket))
+	return b != nil && b.Get(hash.Serialize()) != nil
+}
@@ -0,0 +1,89 @@
+package core
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestRewindTo(t *testing.T) {
+	bc := newTestBlockchain(t, nil)
+	var txs []*Transaction
+	var accounts []*Account // of the first key after every block
+	for i := 0; i < 3; i++ {
+		tx := newTestTx(t, bc, testKeys[0], 100, 0)
+		err := bc.SendTransaction(tx)
+		if err != nil {
+			t.Fatal(err)
+		}
+		mineTestBlock(t, bc)
+		txs = append(txs, tx)
+		accounts = append(accounts, testAccount(t, bc, testAddress(testKeys[0])))
+	}
+	block3, err := bc.BlocksDB.GetBlockByHeight(3)
+	if err != nil {
+		t.Fatal(err)
+	}
+	checkpoint, err := bc.BlocksDB.GetBlockByHeight(2)
+	if err != nil {
+		t.Fatal(err)
+	}
+	bc.Config.Checkpoints = []Checkpoint{{Height: 2, Hash: checkpoint.Hash.Hex(true)}}
+
+	// every case rewinds the chain left by the previous one
+	tests := []struct {
+		name   string
+		height int64
+		undone int
+		err    string
+	}{
+		{"above the tip", 4, 0, "height should be between"},
+		{"below a checkpoint", 1, 0, "is a checkpoint and can not be reverted"},
+		{"to the checkpoint", 2, 1, ""},
+		{"to the tip", 2, 0, ""},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			before := tipHeight(t, bc)
+			undone, err := bc.RewindTo(test.height)
+			if test.err != "" {
+				if err == nil || !strings.Contains(err.Error(), test.err) {
+					t.Fatalf("rewind to %v should be refused with %q, got %v", test.height, test.err, err)
+				}
+				if height := tipHeight(t, bc); height != before {
+					t.Fatalf("tip is moved from %v to %v by a refused rewind", before, height)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			if undone != test.undone || tipHeight(t, bc) != test.height {
+				t.Fatalf("%v blocks are undone to height %v, want %v to %v", undone, tipHeight(t, bc), test.undone, test.height)
+			}
+		})
+	}
+
+	// the undo journal restores the account as it was after the checkpoint
+	if account := testAccount(t, bc, testAddress(testKeys[0])); !reflect.DeepEqual(account, accounts[1]) {
+		t.Fatalf("account is %+v after rewind, want %+v", account, accounts[1])
+	}
+	if !inTxsPool(bc, txs[2].Hash) {
+		t.Fatal("tx of the undone block should go back into Txs-Pool")
+	}
+	if _, err = bc.InsertBlock(block3); err == nil {
+		t.Fatal("undone block should be invalid")
+	}
+	// the new block at height 3 packages another tx as well, so it differs from the undone one
+	err = bc.SendTransaction(newTestTx(t, bc, testKeys[1], 100, 0))
+	if err != nil {
+		t.Fatal(err)
+	}
+	mineTestBlock(t, bc)
+	if height := tipHeight(t, bc); height != 3 {
+		t.Fatalf("tip is at height %v after mining, want 3", height)
+	}
+	if _, err = bc.Verify(); err != nil {
+		t.Fatal(err)
+	}
+}
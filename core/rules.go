@@ -0,0 +1,148 @@
+package core
+
+import (
+	"fmt"
+)
+
+// Fork a hard fork changing some rules from Height on, nil fields keep the rules before the fork
+type Fork struct {
+	Name                 string  `json:"name"`
+	Height               int64   `json:"height"`
+	MaxLengthOfData      *int    `json:"maxLengthOfData,omitempty"`
+	BytesPerDataFee      *int64  `json:"bytesPerDataFee,omitempty"`
+	AmountFeeBasisPoints *int64  `json:"amountFeeBasisPoints,omitempty"`
+	PowAlgorithm         *string `json:"powAlgorithm,omitempty"`
+}
+
+// Rules parameters of execution and validation active at a height, blocks keep validating under the rules of
+// their own height when forks are added later
+type Rules struct {
+	Height               int64
+	Forks                []string // names of active forks
+	MaxLengthOfData      int
+	BytesPerDataFee      int64
+	AmountFeeBasisPoints int64
+	PowAlgorithm         string
+	config               *ChainConfig
+}
+
+// Rules the rules of the block at height, forks are applied in order of their heights
+func (c *ChainConfig) Rules(height int64) *Rules {
+	rules := &Rules{
+		Height:               height,
+		Forks:                []string{},
+		MaxLengthOfData:      MaxLengthOfData,
+		BytesPerDataFee:      BytesPerDataFee,
+		AmountFeeBasisPoints: AmountFeeBasisPoints,
+		PowAlgorithm:         PowSHA256,
+		config:               c,
+	}
+	if height >= c.PowAlgorithmHeight {
+		rules.PowAlgorithm = c.PowAlgorithm
+	}
+	for _, fork := range c.Forks {
+		if height < fork.Height {
+			break
+		}
+		rules.Forks = append(rules.Forks, fork.Name)
+		if fork.MaxLengthOfData != nil {
+			rules.MaxLengthOfData = *fork.MaxLengthOfData
+		}
+		if fork.BytesPerDataFee != nil {
+			rules.BytesPerDataFee = *fork.BytesPerDataFee
+		}
+		if fork.AmountFeeBasisPoints != nil {
+			rules.AmountFeeBasisPoints = *fork.AmountFeeBasisPoints
+		}
+		if fork.PowAlgorithm != nil {
+			rules.PowAlgorithm = *fork.PowAlgorithm
+		}
+	}
+	return rules
+}
+
+// validateForks forks should have unique names, be in order of heights and set legal values
+func (c *ChainConfig) validateForks() error {
+	names := make(map[string]bool)
+	var lastHeight int64
+	for _, fork := range c.Forks {
+		if fork.Name == "" || names[fork.Name] {
+			return fmt.Errorf("fork name %q should be unique and not empty", fork.Name)
+		}
+		names[fork.Name] = true
+		if fork.Height < lastHeight {
+			return fmt.Errorf("fork %v should not be activated before the previous fork", fork.Name)
+		}
+		lastHeight = fork.Height
+		if fork.MaxLengthOfData != nil && *fork.MaxLengthOfData <= 0 {
+			return fmt.Errorf("maxLengthOfData of fork %v should be more than 0", fork.Name)
+		}
+		if fork.BytesPerDataFee != nil && *fork.BytesPerDataFee <= 0 {
+			return fmt.Errorf("bytesPerDataFee of fork %v should be more than 0", fork.Name)
+		}
+		if fork.AmountFeeBasisPoints != nil && (*fork.AmountFeeBasisPoints < 0 || *fork.AmountFeeBasisPoints > basisPoints) {
+			return fmt.Errorf("amountFeeBasisPoints of fork %v should be between 0 and %v", fork.Name, basisPoints)
+		}
+		if fork.PowAlgorithm != nil && *fork.PowAlgorithm != PowSHA256 && *fork.PowAlgorithm != PowScrypt {
+			return fmt.Errorf("unknown powAlgorithm %v of fork %v", *fork.PowAlgorithm, fork.Name)
+		}
+	}
+	return nil
+}
+
+// MinFee the minimum handling fee of a transaction with dataLength bytes of message transferring amount:
+// 1 for every BytesPerDataFee bytes of data plus AmountFeeBasisPoints/10000 of amount, each part at least 1
+func (r *Rules) MinFee(dataLength int, amount int64) (int64, error) {
+	if dataLength < 0 || amount < 0 {
+		return 0, fmt.Errorf("MinFee error: illegal dataLength=%v or amount=%v", dataLength, amount)
+	}
+	// calculate the fee of data
+	dataFee := int64(dataLength) / r.BytesPerDataFee
+	if dataFee < 1 {
+		dataFee = 1
+	}
+	// calculate the fee of amount, split to avoid overflowing amount*AmountFeeBasisPoints
+	whole, rest := amount/basisPoints, amount%basisPoints
+	amountFee := whole*r.AmountFeeBasisPoints + rest*r.AmountFeeBasisPoints/basisPoints
+	if amountFee < 1 {
+		amountFee = 1
+	}
+	if dataFee+amountFee < amountFee {
+		return 0, fmt.Errorf("MinFee error: fee is out of int64 range")
+	}
+	return dataFee + amountFee, nil
+}
+
+// VerifyTransaction check the payload and the fee of tx
+func (r *Rules) VerifyTransaction(tx *Transaction) error {
+	err := tx.Payload.verify(r)
+	if err != nil {
+		return fmt.Errorf("VerifyTransaction error: %v", err)
+	}
+	fee, err := r.MinFee(len(tx.Data()), tx.Amount())
+	if err != nil {
+		return fmt.Errorf("VerifyTransaction error: %v", err)
+	}
+	if tx.Fee < fee {
+		return fmt.Errorf("VerifyTransaction error: fee=%v is less than %v at height %v", tx.Fee, fee, r.Height)
+	}
+	return nil
+}
+
+// verifyDataLength data of a payload should not be longer than MaxLengthOfData
+func (r *Rules) verifyDataLength(data []byte) error {
+	if len(data) > r.MaxLengthOfData {
+		return fmt.Errorf("length of data should not be more than %v at height %v", r.MaxLengthOfData, r.Height)
+	}
+	return nil
+}
+
+// NewPowAlgorithm the proof-of-work algorithm of the rules
+func (r *Rules) NewPowAlgorithm() *PowAlgorithm {
+	switch r.PowAlgorithm {
+	case PowScrypt:
+		return NewScryptPow(int(r.config.ScryptN), int(r.config.ScryptR), int(r.config.ScryptP))
+	default:
+		return NewSHA256Pow()
+	}
+}
@@ -0,0 +1,111 @@
+package core
+
+import (
+	"fmt"
+	"github.com/XiaoYao-0/memory-blockchain/common"
+	"github.com/boltdb/bolt"
+)
+
+// State is a set of accounts which transactions and blocks are executed on
+type State interface {
+	// GetAccount returns a copy of the account, an empty account is returned if it does not exist
+	GetAccount(addr common.Address) (*Account, error)
+	PutAccount(account *Account) error
+}
+
+// bucketState a State stored in the accounts bucket of a bolt transaction
+type bucketState struct {
+	bucket *bolt.Bucket
+}
+
+func (s *bucketState) GetAccount(addr common.Address) (*Account, error) {
+	encodedAccount := s.bucket.Get(addr.Serialize())
+	if encodedAccount == nil {
+		return NewAccount(addr, 0), nil
+	}
+	return DeserializeAccount(encodedAccount)
+}
+
+func (s *bucketState) PutAccount(account *Account) error {
+	return s.bucket.Put(account.Address.Serialize(), account.Serialize())
+}
+
+// CachedState buffer changes in memory until Commit, discard it to drop the changes
+type CachedState struct {
+	base  State
+	dirty map[common.Address]*Account
+}
+
+func NewCachedState(base State) *CachedState {
+	return &CachedState{
+		base:  base,
+		dirty: make(map[common.Address]*Account),
+	}
+}
+
+func (s *CachedState) GetAccount(addr common.Address) (*Account, error) {
+	if account, ok := s.dirty[addr]; ok {
+		return account.Copy(), nil
+	}
+	account, err := s.base.GetAccount(addr)
+	if err != nil {
+		return nil, fmt.Errorf("GetAccount error: %v", err)
+	}
+	return account, nil
+}
+
+func (s *CachedState) PutAccount(account *Account) error {
+	s.dirty[account.Address] = account.Copy()
+	return nil
+}
+
+// Commit write all changes to the base State
+func (s *CachedState) Commit() error {
+	for _, account := range s.dirty {
+		err := s.base.PutAccount(account)
+		if err != nil {
+			return fmt.Errorf("Commit error: %v", err)
+		}
+	}
+	s.dirty = make(map[common.Address]*Account)
+	return nil
+}
+
+// Addresses of the accounts changed since the last Commit
+func (s *CachedState) Addresses() []common.Address {
+	addrs := make([]common.Address, 0, len(s.dirty))
+	for addr := range s.dirty {
+		addrs = append(addrs, addr)
+	}
+	return addrs
+}
+
+// MemoryState a State kept in memory only
+type MemoryState struct {
+	accounts map[common.Address]*Account
+}
+
+func NewMemoryState() *MemoryState {
+	return &MemoryState{accounts: make(map[common.Address]*Account)}
+}
+
+func (s *MemoryState) GetAccount(addr common.Address) (*Account, error) {
+	if account, ok := s.accounts[addr]; ok {
+		return account.Copy(), nil
+	}
+	return NewAccount(addr, 0), nil
+}
+
+func (s *MemoryState) PutAccount(account *Account) error {
+	s.accounts[account.Address] = account.Copy()
+	return nil
+}
+
+// Accounts all accounts which have been put
+func (s *MemoryState) Accounts() []*Account {
+	accounts := make([]*Account, 0, len(s.accounts))
+	for _, account := range s.accounts {
+		accounts = append(accounts, account.Copy())
+	}
+	return accounts
+}
@@ -0,0 +1,113 @@
+package core
+
+import (
+	"bytes"
+	"encoding/gob"
+	"fmt"
+	"github.com/XiaoYao-0/memory-blockchain/common"
+	"github.com/boltdb/bolt"
+)
+
+const (
+	SupplyBucket = "supply_bucket" // hash of block -> Supply of the chain ending with the block
+)
+
+// Supply coins minted and burned by blocks of the chain ending with the block at Height
+type Supply struct {
+	Height  int64
+	Genesis int64 // coins allocated by genesis, it is not stored
+	Minted  int64
+	Burned  int64
+}
+
+// Circulating coins in all accounts
+func (s *Supply) Circulating() int64 {
+	return s.Genesis + s.Minted - s.Burned
+}
+
+// next the supply after block is applied with reward
+func (s *Supply) next(block *Block, reward Reward) *Supply {
+	return &Supply{
+		Height:  block.Header.Height,
+		Genesis: s.Genesis,
+		Minted:  s.Minted + reward.Subsidy,
+		Burned:  s.Burned + reward.Burned,
+	}
+}
+
+func getSupply(tx *bolt.Tx, hash common.Hash) (*Supply, error) {
+	b := tx.Bucket([]byte(SupplyBucket))
+	if b == nil {
+		return nil, fmt.Errorf("bucket %v do not exist", SupplyBucket)
+	}
+	encodedSupply := b.Get(hash.Serialize())
+	if encodedSupply == nil {
+		return nil, fmt.Errorf("supply of block %v do not exist", hash.Hex(true))
+	}
+	return DeserializeSupply(encodedSupply)
+}
+
+// putSupply record the supply of the chain ending with block, the supply of its parent should have been recorded
+func putSupply(tx *bolt.Tx, block *Block, engine ConsensusEngine) error {
+	b := tx.Bucket([]byte(SupplyBucket))
+	if b == nil {
+		return fmt.Errorf("bucket %v do not exist", SupplyBucket)
+	}
+	parent, err := getSupply(tx, block.Header.PrevBlockHash)
+	if err != nil {
+		return err
+	}
+	supply := parent.next(block, engine.CalculateReward(&block.Header, block.Fees()))
+	return b.Put(block.Hash.Serialize(), supply.Serialize())
+}
+
+// GetSupply returns the supply of the main chain
+func (bc *Blockchain) GetSupply() (*Supply, error) {
+	var supply *Supply
+	err := bc.DB.View(func(tx *bolt.Tx) error {
+		tip, txError := getTip(tx)
+		if txError != nil {
+			return txError
+		}
+		supply, txError = getSupply(tx, tip)
+		return txError
+	})
+	if err != nil {
+		return nil, fmt.Errorf("GetSupply error: %v", err)
+	}
+	supply.Genesis = bc.Genesis.TotalAlloc()
+	return supply, nil
+}
+
+func (s *Supply) Output() string {
+	return fmt.Sprintf("Supply at height %v\n"+
+		"  Genesis: %v\n"+
+		"  Minted: %v\n"+
+		"  Burned: %v\n"+
+		"  Circulating: %v\n",
+		s.Height,
+		s.Genesis,
+		s.Minted,
+		s.Burned,
+		s.Circulating())
+}
+
+func (s *Supply) Serialize() []byte {
+	var result bytes.Buffer
+	encoder := gob.NewEncoder(&result)
+
+	_ = encoder.Encode(s)
+
+	return result.Bytes()
+}
+
+func DeserializeSupply(d []byte) (*Supply, error) {
+	var supply Supply
+
+	decoder := gob.NewDecoder(bytes.NewReader(d))
+	err := decoder.Decode(&supply)
+	if err != nil {
+		return nil, fmt.Errorf("DeserializeSupply error: %v", err)
+	}
+	return &supply, nil
+}
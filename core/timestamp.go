@@ -0,0 +1,44 @@
+package core
+
+import (
+	"fmt"
+	"sort"
+	"time"
+)
+
+// Clock returns the current time, it can be replaced to validate timestamps deterministically
+type Clock func() time.Time
+
+// medianTimePast the median timestamp of parent and at most MedianTimeSpan-1 blocks before it
+func medianTimePast(chain HeaderReader, config *ChainConfig, parent *BlockHeader) (int64, error) {
+	timestamps := []int64{parent.Timestamp}
+	header := parent
+	for int64(len(timestamps)) < config.MedianTimeSpan && header.Height > 0 {
+		var err error
+		header, err = chain.GetHeader(header.PrevBlockHash)
+		if err != nil {
+			return 0, fmt.Errorf("medianTimePast error: %v", err)
+		}
+		timestamps = append(timestamps, header.Timestamp)
+	}
+	sort.Slice(timestamps, func(i, j int) bool { return timestamps[i] < timestamps[j] })
+	return timestamps[len(timestamps)/2], nil
+}
+
+// verifyTimestamp the timestamp of header should be greater than the median time past of parent and
+// no more than MaxFutureDrift seconds ahead of now
+func verifyTimestamp(chain HeaderReader, config *ChainConfig, header, parent *BlockHeader, now time.Time) error {
+	median, err := medianTimePast(chain, config, parent)
+	if err != nil {
+		return fmt.Errorf("verifyTimestamp error: %v", err)
+	}
+	if header.Timestamp <= median {
+		return fmt.Errorf("verifyTimestamp error: timestamp %v is not greater than the median %v of previous blocks",
+			header.Timestamp, median)
+	}
+	if header.Timestamp > now.Unix()+config.MaxFutureDrift {
+		return fmt.Errorf("verifyTimestamp error: timestamp %v is more than %v seconds ahead of local time %v",
+			header.Timestamp, config.MaxFutureDrift, now.Unix())
+	}
+	return nil
+}
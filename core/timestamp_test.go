@@ -0,0 +1,93 @@
+package core
+
+import (
+	"github.com/boltdb/bolt"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+// newTestBlocksDB open the blocks of a chain of the dev engine in a temporary directory, checked against clock
+func newTestBlocksDB(t *testing.T, clock Clock) *BlocksDB {
+	db, err := bolt.Open(filepath.Join(t.TempDir(), "chain.db"), 0666, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { _ = db.Close() })
+	config := DefaultChainConfig()
+	config.Engine = EngineDev
+	engine, err := NewConsensusEngine(config)
+	if err != nil {
+		t.Fatal(err)
+	}
+	blocksDB, err := NewBlocksDB(db, config, engine)
+	if err != nil {
+		t.Fatal(err)
+	}
+	blocksDB.Clock = clock
+	return blocksDB
+}
+
+func addTestBlock(db *BlocksDB, block *Block) error {
+	return db.DB.Update(func(tx *bolt.Tx) error {
+		return db.AddBlock(tx, block)
+	})
+}
+
+// newTestBlock a block of the dev engine with only the coinbase on top of parent
+func newTestBlock(db *BlocksDB, parent *BlockHeader, timestamp int64) *Block {
+	block := NewBlock(nil, parent)
+	block.Header.Timestamp = timestamp
+	block.Txs = []*Transaction{NewCoinbaseTransaction(&block.Header, db.Engine.CalculateReward(&block.Header, 0))}
+	block.Header.MerkleRoot = MerkleRoot(block.TxsHashes())
+	block.Hash = block.Header.Hash()
+	return block
+}
+
+func TestVerifyTimestamp(t *testing.T) {
+	now := time.Unix(GenesisTimestamp+100000, 0)
+	db := newTestBlocksDB(t, func() time.Time { return now })
+	tip, err := db.GetLastBlockHash()
+	if err != nil {
+		t.Fatal(err)
+	}
+	parent, err := db.GetHeader(tip)
+	if err != nil {
+		t.Fatal(err)
+	}
+	// timestamps of the chain are genesis, genesis+10, genesis+20 and genesis+30, the median is genesis+20
+	for i := 0; i < 3; i++ {
+		block := newTestBlock(db, parent, parent.Timestamp+10)
+		err = addTestBlock(db, block)
+		if err != nil {
+			t.Fatal(err)
+		}
+		parent = &block.Header
+	}
+	median := GenesisTimestamp + 20
+	drift := db.Config.MaxFutureDrift
+
+	tests := []struct {
+		name      string
+		timestamp int64
+		err       string
+	}{
+		{"at the median time past", median, "not greater than the median"},
+		{"beyond the allowed drift", now.Unix() + drift + 1, "seconds ahead of local time"},
+		{"just above the median time past", median + 1, ""},
+		{"at the allowed drift", now.Unix() + drift, ""},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			// every block extends the same parent, accepted ones are stored as side blocks
+			err := addTestBlock(db, newTestBlock(db, parent, test.timestamp))
+			if test.err == "" && err != nil {
+				t.Fatalf("block at %v should be accepted: %v", test.timestamp, err)
+			}
+			if test.err != "" && (err == nil || !strings.Contains(err.Error(), test.err)) {
+				t.Fatalf("block at %v should be rejected with %q, got %v", test.timestamp, test.err, err)
+			}
+		})
+	}
+}
@@ -2,170 +2,233 @@ package core
 
 import (
 	"bytes"
+	"crypto/ecdsa"
+	"crypto/rand"
 	"crypto/sha256"
 	"encoding/gob"
 	"fmt"
 	"github.com/XiaoYao-0/memory-blockchain/common"
-	"math"
 )
 
+// TxVersion version of the transaction envelope created by this node, transactions decoded from the flat
+// struct used before envelopes have version 0 and type TxLegacy.
+// Version 2 adds ValidUntil, which should be 0 in older versions
+const TxVersion uint8 = 2
+
+// Transaction an envelope of the fields common to every kind of transaction and a payload of its kind
 type Transaction struct {
-	From   common.Address
-	To     common.Address
-	Data   []byte // less than 256 bytes
-	Amount int64
-	Fee    int64
-	Hash   common.Hash
+	Version uint8
+	From    common.Address
+	Fee     int64
+	Nonce   uint64 // sequence number of the transaction in the sender's account
+	// ValidUntil the last block height, or Unix timestamp if not less than ValidUntilTimestampThreshold,
+	// at which tx can be packaged, 0 if tx never expires
+	ValidUntil int64
+	Payload    TxPayload
+	Hash       common.Hash
+	// PublicKey of the sender, From must be derived from it
+	PublicKey []byte
+	// Signature = ECDSA(Hash) with the private key of the sender
+	Signature []byte
 }
 
+// rules before any fork, see Rules
 const (
-	MaxRetryOfExecution         = 5      // max retry number of execution
-	MaxLengthOfData             = 256    // max length of data in a Tx
-	DataFeeRatio        float64 = 0.1    // data fee ratio of tx handling
-	AmountFeeRatio      float64 = 0.0001 // amount fee ratio of tx handling
+	MaxLengthOfData      = 256 // max length of data in a Tx
+	BytesPerDataFee      = 10  // every 10 bytes of data cost 1
+	AmountFeeBasisPoints = 1   // amount fee in 1/10000 of the amount
+	basisPoints          = 10000
 )
 
-func NewTransaction(from, to common.Address, message string, amount int64) (*Transaction, error) {
+// NewTransaction a message transaction if message is not empty, otherwise a transfer.
+// fee is the fee offered by the sender, 0 to pay the minimum one of rules, which should be the rules of the next block.
+// validUntil is 0 or the last height or timestamp to package it, see Transaction.ValidUntil
+func NewTransaction(rules *Rules, from, to common.Address, message string, amount, fee, validUntil int64, nonce uint64) (*Transaction, error) {
 	// validate the input
 	if amount < 0 {
 		return nil, fmt.Errorf("amount should not be less than 0")
 	}
-	data := []byte(message)
-	if len(data) > MaxLengthOfData {
-		return nil, fmt.Errorf("length of data should not be more than %v", MaxLengthOfData)
+	var payload TxPayload = &TransferPayload{To: to, Amount: amount}
+	if len(message) != 0 {
+		payload = &MessagePayload{To: to, Data: []byte(message), Amount: amount}
 	}
-	// calculate the fee of data
-	dataFeeFloat64 := math.Floor(DataFeeRatio * float64(len(data)))
-	if dataFeeFloat64 >= math.MaxInt64 || dataFeeFloat64 <= math.MinInt64 {
-		return nil, fmt.Errorf("dataFeeFloat64=%v is out of int64 range", dataFeeFloat64)
+	return newTransaction(rules, from, nonce, fee, validUntil, payload)
+}
+
+// newTransaction wrap payload in an envelope paying fee, or the minimum fee of rules if fee is 0
+func newTransaction(rules *Rules, from common.Address, nonce uint64, fee, validUntil int64, payload TxPayload) (*Transaction, error) {
+	if validUntil < 0 {
+		return nil, fmt.Errorf("validUntil should not be less than 0")
 	}
-	dataFee := int64(dataFeeFloat64)
-	if dataFee < 1 {
-		dataFee = 1
+	err := payload.verify(rules)
+	if err != nil {
+		return nil, err
 	}
-	// calculate the fee of amount
-	amountFeeFloat64 := math.Floor(AmountFeeRatio * float64(amount))
-	if amountFeeFloat64 >= math.MaxInt64 || amountFeeFloat64 <= math.MinInt64 {
-		return nil, fmt.Errorf("amountFeeFloat64=%v is out of int64 range", amountFeeFloat64)
+	minFee, err := rules.MinFee(len(payload.data()), payload.amount())
+	if err != nil {
+		return nil, err
 	}
-	amountFee := int64(amountFeeFloat64)
-	if amountFee < 1 {
-		amountFee = 1
+	if fee == 0 {
+		fee = minFee
+	}
+	if fee < minFee {
+		return nil, fmt.Errorf("fee should not be less than %v", minFee)
 	}
 	tx := &Transaction{
-		From:   from,
-		To:     to,
-		Data:   data,
-		Amount: amount,
-		Fee:    dataFee + amountFee,
-	}
-	// calculate hash of tx
-	txData := bytes.Join(
-		[][]byte{
-			tx.From.Bytes(),
-			tx.To.Bytes(),
-			tx.Data,
-			IntToHex(tx.Amount),
-			IntToHex(tx.Fee),
-		},
-		[]byte{},
-	)
-	tx.Hash = sha256.Sum256(txData)
+		Version:    TxVersion,
+		From:       from,
+		Fee:        fee,
+		Nonce:      nonce,
+		ValidUntil: validUntil,
+		Payload:    payload,
+	}
+	tx.Hash = tx.CalcHash()
 	return tx, nil
 }
 
-// Exec transaction will roll back if failed
-func (tx *Transaction) Exec(db *AccountsDB) error {
-	var err error
-	err = db.DecreaseBalanceOf(tx.From, tx.Fee+tx.Amount)
+// Type the kind of the payload
+func (tx *Transaction) Type() TxType {
+	return tx.Payload.Type()
+}
+
+// Amount coins paid by the sender besides the fee
+func (tx *Transaction) Amount() int64 {
+	return tx.Payload.amount()
+}
+
+// Data message bytes carried by tx, the fee is charged on their length
+func (tx *Transaction) Data() []byte {
+	return tx.Payload.data()
+}
+
+// CalcHash Hash = SHA256(Version + Type + From + Fee + Nonce + ValidUntil + encoded Payload), signature is not included,
+// ValidUntil is only included since version 2.
+// Legacy transactions keep the hash they had before the envelope, see LegacyPayload
+func (tx *Transaction) CalcHash() common.Hash {
+	if legacy, ok := tx.Payload.(*LegacyPayload); ok {
+		return legacy.hash(tx)
+	}
+	e := &payloadEncoder{}
+	e.uint8(tx.Version)
+	e.uint8(uint8(tx.Type()))
+	e.address(tx.From)
+	e.int64(tx.Fee)
+	e.int64(int64(tx.Nonce))
+	if tx.Version >= 2 {
+		e.int64(tx.ValidUntil)
+	}
+	e.bytes(tx.Payload.encode())
+	return sha256.Sum256(e.buf.Bytes())
+}
+
+// Sign tx with the private key of the sender
+func (tx *Transaction) Sign(key *ecdsa.PrivateKey) error {
+	if common.PubKeyToAddress(&key.PublicKey) != tx.From {
+		return fmt.Errorf("Sign error: key does not belong to %v", tx.From.Hex(true))
+	}
+	hash := tx.CalcHash()
+	signature, err := ecdsa.SignASN1(rand.Reader, key, hash.Bytes())
+	if err != nil {
+		return fmt.Errorf("Sign error: %v", err)
+	}
+	tx.Hash = hash
+	tx.PublicKey = common.PublicKeyBytes(&key.PublicKey)
+	tx.Signature = signature
+	return nil
+}
+
+// Verify check that tx is signed by the owner of From and is not modified after signing
+func (tx *Transaction) Verify() error {
+	if tx.IsCoinbase() {
+		return fmt.Errorf("Verify error: coinbase transaction %v is only valid as the first one of its block", tx.Hash.Hex(true))
+	}
+	if len(tx.Signature) == 0 || len(tx.PublicKey) == 0 {
+		return fmt.Errorf("Verify error: transaction %v is not signed", tx.Hash.Hex(true))
+	}
+	err := tx.verifyValidUntil()
 	if err != nil {
-		return fmt.Errorf("Exec error: %v", err)
+		return fmt.Errorf("Verify error: %v", err)
 	}
-	err = db.IncreaseBalanceOf(tx.To, tx.Amount)
+	pub, err := common.PublicKeyFromBytes(tx.PublicKey)
 	if err != nil {
-		var err1 error
-		for i := 0; i < MaxRetryOfExecution; i++ {
-			err1 = db.IncreaseBalanceOf(tx.From, tx.Fee+tx.Amount)
-			if err1 != nil {
-				continue
-			}
-			break
-		}
-		if err1 != nil {
-			panic(fmt.Errorf("failed to exec tx (%v) and roll back it: %v", tx.Hash.Hex(true), err1))
-		}
-		return fmt.Errorf("Exec error: %v", err)
+		return fmt.Errorf("Verify error: %v", err)
 	}
-	if len(tx.Data) != 0 {
-		err = db.PutMessagesTo(tx.To, tx.Data)
-		if err != nil {
-			var err1 error
-			for i := 0; i < MaxRetryOfExecution; i++ {
-				err1 = db.IncreaseBalanceOf(tx.From, tx.Fee+tx.Amount)
-				if err1 != nil {
-					continue
-				}
-				break
-			}
-			if err1 != nil {
-				panic(fmt.Errorf("failed to exec tx (%v) and roll back it: %v", tx.Hash.Hex(true), err1))
-			}
-			for i := 0; i < MaxRetryOfExecution; i++ {
-				err1 = db.DecreaseBalanceOf(tx.To, tx.Amount)
-				if err1 != nil {
-					continue
-				}
-				break
-			}
-			if err1 != nil {
-				panic(fmt.Errorf("failed to exec tx (%v) and roll back it: %v", tx.Hash.Hex(true), err1))
-			}
-			return fmt.Errorf("Exec error: %v", err)
-		}
+	if common.PubKeyToAddress(pub) != tx.From {
+		return fmt.Errorf("Verify error: public key does not match sender %v", tx.From.Hex(true))
+	}
+	hash := tx.CalcHash()
+	if hash != tx.Hash {
+		return fmt.Errorf("Verify error: hash mismatch: have %v, want %v", tx.Hash.Hex(true), hash.Hex(true))
+	}
+	if !ecdsa.VerifyASN1(pub, hash.Bytes(), tx.Signature) {
+		return fmt.Errorf("Verify error: invalid signature of transaction %v", tx.Hash.Hex(true))
 	}
 	return nil
 }
 
-// RollBack retry until the maximum number of retries is reached and crash
-func (tx *Transaction) RollBack(db *AccountsDB) {
-	var err error
-	for i0 := 0; i0 < MaxRetryOfExecution; i0++ {
-		err = db.IncreaseBalanceOf(tx.From, tx.Fee+tx.Amount)
-		if err != nil {
-			continue
-		}
-		err = db.DecreaseBalanceOf(tx.To, tx.Amount)
-		if err != nil {
-			continue
-		}
-		if len(tx.Data) != 0 {
-			err = db.DeleteMessageOf(tx.To)
-			if err != nil {
-				continue
-			}
-		}
-		break
+// Exec transaction on state under rules of the block, the nonce of tx should be the next expected nonce of the sender.
+// state may be partially changed if it fails, so execute it on a CachedState and discard the cache on error
+func (tx *Transaction) Exec(state State, rules *Rules) error {
+	if tx.IsCoinbase() {
+		return fmt.Errorf("Exec error: coinbase transaction should be executed with its block")
 	}
+	err := rules.VerifyTransaction(tx)
 	if err != nil {
-		panic(fmt.Errorf("tx roll back error\n%v", tx.Output()))
+		return fmt.Errorf("Exec error: %v", err)
 	}
+	amount := tx.Amount()
+	if amount < 0 || tx.Fee < 0 || amount+tx.Fee < amount {
+		return fmt.Errorf("Exec error: illegal amount=%v or fee=%v", amount, tx.Fee)
+	}
+	from, err := state.GetAccount(tx.From)
+	if err != nil {
+		return fmt.Errorf("Exec error: %v", err)
+	}
+	if tx.Nonce != from.Nonce {
+		return fmt.Errorf("Exec error: nonce=%v, expected %v", tx.Nonce, from.Nonce)
+	}
+	if from.Balance < tx.Fee+amount {
+		return fmt.Errorf("Exec error: balance=%v is less than %v", from.Balance, tx.Fee+amount)
+	}
+	from.Balance -= tx.Fee + amount
+	from.Nonce++
+	err = state.PutAccount(from)
+	if err != nil {
+		return fmt.Errorf("Exec error: %v", err)
+	}
+	// the payload of each type credits its own receivers
+	err = tx.Payload.exec(state)
+	if err != nil {
+		return fmt.Errorf("Exec error: %v", err)
+	}
+	return nil
 }
 
 func (tx *Transaction) Output() string {
-	return fmt.Sprintf("Transaction %v\n"+
+	output := fmt.Sprintf("Transaction %v\n"+
+		"  Type: %v (version %v)\n"+
 		"  From: %v\n"+
-		"  To: %v\n"+
-		"  Data: %s\n"+
-		"  Amount: %v\n"+
+		"%v"+
 		"  Fee: %v\n"+
+		"  Nonce: %v\n"+
 		"  Hash: %v\n",
 		tx.Hash.Hex(true),
+		tx.Type(),
+		tx.Version,
 		tx.From.Hex(true),
-		tx.To.Hex(true),
-		tx.Data,
-		tx.Amount,
+		tx.Payload.output(),
 		tx.Fee,
+		tx.Nonce,
 		tx.Hash.Hex(true))
+	if tx.ValidUntil != 0 {
+		output += fmt.Sprintf("  Valid until: %v\n", validUntilOutput(tx.ValidUntil))
+	}
+	return output
+}
+
+// Size the serialized size of tx
+func (tx *Transaction) Size() int64 {
+	return int64(len(tx.Serialize()))
 }
 
 func (tx *Transaction) Serialize() []byte {
@@ -177,13 +240,85 @@ func (tx *Transaction) Serialize() []byte {
 	return result.Bytes()
 }
 
+// txEnvelope the encoding of a Transaction, Payload is encoded by its type
+type txEnvelope struct {
+	Version    uint8
+	Type       TxType
+	From       common.Address
+	Fee        int64
+	Nonce      uint64
+	ValidUntil int64
+	Payload    []byte
+	Hash       common.Hash
+	PublicKey  []byte
+	Signature  []byte
+}
+
+func (tx *Transaction) GobEncode() ([]byte, error) {
+	var result bytes.Buffer
+	err := gob.NewEncoder(&result).Encode(&txEnvelope{
+		Version:    tx.Version,
+		Type:       tx.Type(),
+		From:       tx.From,
+		Fee:        tx.Fee,
+		Nonce:      tx.Nonce,
+		ValidUntil: tx.ValidUntil,
+		Payload:    tx.Payload.encode(),
+		Hash:       tx.Hash,
+		PublicKey:  tx.PublicKey,
+		Signature:  tx.Signature,
+	})
+	if err != nil {
+		return nil, fmt.Errorf("GobEncode error: %v", err)
+	}
+	return result.Bytes(), nil
+}
+
+func (tx *Transaction) GobDecode(d []byte) error {
+	var envelope txEnvelope
+	err := gob.NewDecoder(bytes.NewReader(d)).Decode(&envelope)
+	if err != nil {
+		return fmt.Errorf("GobDecode error: %v", err)
+	}
+	if envelope.Version > TxVersion {
+		return fmt.Errorf("GobDecode error: unsupported transaction version %v", envelope.Version)
+	}
+	if (envelope.Version == 0) != (envelope.Type == TxLegacy) {
+		return fmt.Errorf("GobDecode error: transaction of type %v should not have version %v", envelope.Type, envelope.Version)
+	}
+	if envelope.Version < 2 && envelope.ValidUntil != 0 {
+		return fmt.Errorf("GobDecode error: transaction of version %v should not have ValidUntil", envelope.Version)
+	}
+	payload, err := decodePayload(envelope.Type, envelope.Payload)
+	if err != nil {
+		return fmt.Errorf("GobDecode error: %v", err)
+	}
+	*tx = Transaction{
+		Version:    envelope.Version,
+		From:       envelope.From,
+		Fee:        envelope.Fee,
+		Nonce:      envelope.Nonce,
+		ValidUntil: envelope.ValidUntil,
+		Payload:    payload,
+		Hash:       envelope.Hash,
+		PublicKey:  envelope.PublicKey,
+		Signature:  envelope.Signature,
+	}
+	return nil
+}
+
+// DeserializeTransaction decode an envelope, or a flat legacy transaction stored before envelopes
 func DeserializeTransaction(d []byte) (*Transaction, error) {
 	var transaction Transaction
 
 	decoder := gob.NewDecoder(bytes.NewReader(d))
 	err := decoder.Decode(&transaction)
 	if err != nil {
-		return nil, fmt.Errorf("DeserializeTransaction error: %v", err)
+		var legacy legacyTransaction
+		if gob.NewDecoder(bytes.NewReader(d)).Decode(&legacy) != nil {
+			return nil, fmt.Errorf("DeserializeTransaction error: %v", err)
+		}
+		return legacy.upgrade(), nil
 	}
 	return &transaction, nil
 }
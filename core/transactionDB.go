@@ -1,9 +1,11 @@
 package core
 
 import (
+	"bytes"
 	"fmt"
 	"github.com/XiaoYao-0/memory-blockchain/common"
 	"github.com/boltdb/bolt"
+	"time"
 )
 
 type TransactionsDB struct {
@@ -11,17 +13,15 @@ type TransactionsDB struct {
 }
 
 const (
-	TransactionsDBFile = "./data/transactions.db"
 	TransactionsBucket = "transactions_bucket"
+	TxLookupBucket     = "tx_lookup_bucket" // hash of transaction -> hash of the block packaging it
+	// LegacyTransactionsDBFile transactions written before blocks, accounts and transactions shared chain.db,
+	// in TransactionsBucket with flat legacy transactions
+	LegacyTransactionsDBFile = "./data/transactions.db"
 )
 
-func NewTransactionsDB() (*TransactionsDB, error) {
-	db, err := bolt.Open(TransactionsDBFile, 0666, nil)
-	if err != nil {
-		return nil, fmt.Errorf("NewTransactionsDB error: %v", err)
-	}
-
-	err = db.Update(func(tx *bolt.Tx) error {
+func NewTransactionsDB(db *bolt.DB) (*TransactionsDB, error) {
+	err := db.Update(func(tx *bolt.Tx) error {
 		b := tx.Bucket([]byte(TransactionsBucket))
 
 		if b == nil {
@@ -30,6 +30,10 @@ func NewTransactionsDB() (*TransactionsDB, error) {
 			if txError != nil {
 				return txError
 			}
+			_, txError = tx.CreateBucket([]byte(TxLookupBucket))
+			if txError != nil {
+				return txError
+			}
 		}
 		return nil
 	})
@@ -41,10 +45,6 @@ func NewTransactionsDB() (*TransactionsDB, error) {
 	}, nil
 }
 
-func (db *TransactionsDB) Close() {
-	_ = db.DB.Close()
-}
-
 func (db *TransactionsDB) GetTransaction(hash common.Hash) (*Transaction, error) {
 	var transaction *Transaction
 	err := db.DB.View(func(tx *bolt.Tx) error {
@@ -70,35 +70,112 @@ func (db *TransactionsDB) GetTransaction(hash common.Hash) (*Transaction, error)
 	return transaction, nil
 }
 
-func (db *TransactionsDB) AddTransaction(transaction *Transaction) error {
-	var err error
-	err = db.DB.Update(func(tx *bolt.Tx) error {
-		b := tx.Bucket([]byte(TransactionsBucket))
+// GetBlockHashOf returns the hash of the block packaging the transaction
+func (db *TransactionsDB) GetBlockHashOf(hash common.Hash) (common.Hash, error) {
+	var blockHash common.Hash
+	err := db.DB.View(func(tx *bolt.Tx) error {
+		b := tx.Bucket([]byte(TxLookupBucket))
 
 		if b == nil {
-			return fmt.Errorf("bucket %v do not exist", TransactionsBucket)
+			return fmt.Errorf("bucket %v do not exist", TxLookupBucket)
 		}
-		var txError error
-		txError = b.Put(transaction.Hash.Serialize(), transaction.Serialize())
-		if txError != nil {
-			return txError
+		encodedHash := b.Get(hash.Serialize())
+		if encodedHash == nil {
+			return fmt.Errorf("transaction %v is not packaged", hash.Hex(true))
 		}
-		return nil
+		var txError error
+		blockHash, txError = common.DeserializeHash(encodedHash)
+		return txError
 	})
+	if err != nil {
+		return common.Hash{}, fmt.Errorf("GetBlockHashOf error: %v", err)
+	}
+	return blockHash, nil
+}
+
+// AddTransaction write transaction packaged by block blockHash in tx, it is committed together with the block
+func (db *TransactionsDB) AddTransaction(tx *bolt.Tx, transaction *Transaction, blockHash common.Hash) error {
+	b := tx.Bucket([]byte(TransactionsBucket))
+	lb := tx.Bucket([]byte(TxLookupBucket))
+	if b == nil || lb == nil {
+		return fmt.Errorf("AddTransaction error: bucket %v or %v do not exist", TransactionsBucket, TxLookupBucket)
+	}
+	err := b.Put(transaction.Hash.Serialize(), transaction.Serialize())
 	if err != nil {
 		return fmt.Errorf("AddTransaction error: %v", err)
 	}
+	err = lb.Put(transaction.Hash.Serialize(), blockHash.Serialize())
+	if err != nil {
+		return fmt.Errorf("AddTransaction error: %v", err)
+	}
+	return nil
+}
+
+// RemoveTransaction delete transaction packaged by block blockHash in tx when the block leaves the main chain,
+// nothing is deleted if the transaction is recorded for another block
+func (db *TransactionsDB) RemoveTransaction(tx *bolt.Tx, hash common.Hash, blockHash common.Hash) error {
+	b := tx.Bucket([]byte(TransactionsBucket))
+	lb := tx.Bucket([]byte(TxLookupBucket))
+	if b == nil || lb == nil {
+		return fmt.Errorf("RemoveTransaction error: bucket %v or %v do not exist", TransactionsBucket, TxLookupBucket)
+	}
+	encodedHash := lb.Get(hash.Serialize())
+	if encodedHash == nil || !bytes.Equal(encodedHash, blockHash.Serialize()) {
+		return nil
+	}
+	err := b.Delete(hash.Serialize())
+	if err != nil {
+		return fmt.Errorf("RemoveTransaction error: %v", err)
+	}
+	err = lb.Delete(hash.Serialize())
+	if err != nil {
+		return fmt.Errorf("RemoveTransaction error: %v", err)
+	}
 	return nil
 }
 
-func (db *TransactionsDB) AddTransactionWithRetry(transaction *Transaction, maxRetry int) error {
-	var err error
-	for i := 0; i < maxRetry; i++ {
-		err = db.AddTransaction(transaction)
-		if err != nil {
-			continue
+// ImportLegacyTransactions copy the transactions of file, a transactions.db written before chain.db, which are not in db yet.
+// They are stored as envelopes of TxLegacy without a block in TxLookupBucket, it returns the number of copied ones
+func (db *TransactionsDB) ImportLegacyTransactions(file string) (int, error) {
+	legacyDB, err := bolt.Open(file, 0666, &bolt.Options{ReadOnly: true, Timeout: time.Second})
+	if err != nil {
+		return 0, fmt.Errorf("ImportLegacyTransactions error: %v", err)
+	}
+	defer legacyDB.Close()
+	imported := 0
+	err = db.DB.Update(func(tx *bolt.Tx) error {
+		b := tx.Bucket([]byte(TransactionsBucket))
+		if b == nil {
+			return fmt.Errorf("bucket %v do not exist", TransactionsBucket)
 		}
-		break
+		return legacyDB.View(func(legacyTx *bolt.Tx) error {
+			lb := legacyTx.Bucket([]byte(TransactionsBucket))
+			if lb == nil {
+				return nil
+			}
+			return lb.ForEach(func(k, v []byte) error {
+				if b.Get(k) != nil {
+					return nil
+				}
+				transaction, txError := DeserializeTransaction(v)
+				if txError != nil {
+					return txError
+				}
+				if transaction.Type() != TxLegacy || transaction.CalcHash() != transaction.Hash ||
+					!bytes.Equal(k, transaction.Hash.Serialize()) {
+					return fmt.Errorf("transaction %x is not a legacy transaction stored under its hash", k)
+				}
+				txError = b.Put(k, transaction.Serialize())
+				if txError != nil {
+					return txError
+				}
+				imported++
+				return nil
+			})
+		})
+	})
+	if err != nil {
+		return 0, fmt.Errorf("ImportLegacyTransactions error: %v", err)
 	}
-	return err
+	return imported, nil
 }
@@ -0,0 +1,119 @@
+package core
+
+import (
+	"bytes"
+	"encoding/gob"
+	"fmt"
+	"github.com/XiaoYao-0/memory-blockchain/common"
+	"time"
+)
+
+const (
+	// ValidUntilTimestampThreshold ValidUntil of a transaction less than it is a block height, otherwise a Unix timestamp
+	ValidUntilTimestampThreshold int64 = 500000000
+)
+
+// ExpiredAt whether tx can no longer be packaged in a block at height with timestamp
+func (tx *Transaction) ExpiredAt(height, timestamp int64) bool {
+	switch {
+	case tx.ValidUntil == 0:
+		return false
+	case tx.ValidUntil < ValidUntilTimestampThreshold:
+		return height > tx.ValidUntil
+	default:
+		return timestamp > tx.ValidUntil
+	}
+}
+
+// verifyValidUntil ValidUntil is not covered by the hash of envelopes before version 2
+func (tx *Transaction) verifyValidUntil() error {
+	if tx.ValidUntil < 0 || (tx.Version < 2 && tx.ValidUntil != 0) {
+		return fmt.Errorf("illegal ValidUntil=%v of transaction version %v", tx.ValidUntil, tx.Version)
+	}
+	return nil
+}
+
+// expiredReason why tx can not be packaged in a block at height with timestamp
+func (tx *Transaction) expiredReason(height, timestamp int64) string {
+	if tx.ValidUntil < ValidUntilTimestampThreshold {
+		return fmt.Sprintf("expired at height %v, it is valid until height %v", height, tx.ValidUntil)
+	}
+	return fmt.Sprintf("expired at %v, it is valid until %v",
+		time.Unix(timestamp, 0).UTC().Format(time.RFC3339), validUntilOutput(tx.ValidUntil))
+}
+
+func validUntilOutput(validUntil int64) string {
+	if validUntil < ValidUntilTimestampThreshold {
+		return fmt.Sprintf("height %v", validUntil)
+	}
+	return time.Unix(validUntil, 0).UTC().Format(time.RFC3339)
+}
+
+// DroppedTx a transaction removed from the Txs-Pool for good without being packaged, and why
+type DroppedTx struct {
+	Tx     *Transaction
+	Reason string
+}
+
+func (d *DroppedTx) Serialize() []byte {
+	var result bytes.Buffer
+	encoder := gob.NewEncoder(&result)
+
+	_ = encoder.Encode(d)
+
+	return result.Bytes()
+}
+
+func DeserializeDroppedTx(d []byte) (*DroppedTx, error) {
+	var dropped DroppedTx
+
+	decoder := gob.NewDecoder(bytes.NewReader(d))
+	err := decoder.Decode(&dropped)
+	if err != nil {
+		return nil, fmt.Errorf("DeserializeDroppedTx error: %v", err)
+	}
+	return &dropped, nil
+}
+
+// nextBlockTime the timestamp of a block mined now on tip, later than the median time past even if the local clock is behind
+func (bc *Blockchain) nextBlockTime(tip *BlockHeader) (int64, error) {
+	timestamp := bc.BlocksDB.Clock().Unix()
+	median, err := medianTimePast(bc.BlocksDB, bc.Config, tip)
+	if err != nil {
+		return 0, err
+	}
+	if timestamp <= median {
+		timestamp = median + 1
+	}
+	return timestamp, nil
+}
+
+// dropUnpackableTxs drop txs in the pool which can never be packaged in the block at height with timestamp or later:
+// expired ones and ones whose nonce is already used on the main chain, e.g. by the winner of a replacement
+func (bc *Blockchain) dropUnpackableTxs(height, timestamp int64) error {
+	var dropped []*DroppedTx
+	nonces := make(map[common.Address]uint64)
+	for _, tx := range bc.TxsPoolDB.GetAllTxs() {
+		if tx.ExpiredAt(height, timestamp) {
+			dropped = append(dropped, &DroppedTx{Tx: tx, Reason: tx.expiredReason(height, timestamp)})
+			continue
+		}
+		nonce, ok := nonces[tx.From]
+		if !ok {
+			account, err := bc.AccountsDB.GetAccountOf(tx.From)
+			if err != nil {
+				return err
+			}
+			nonce = account.Nonce
+			nonces[tx.From] = nonce
+		}
+		if tx.Nonce < nonce {
+			dropped = append(dropped, &DroppedTx{Tx: tx, Reason: fmt.Sprintf("nonce %v is already used, "+
+				"next nonce of %v on the main chain is %v", tx.Nonce, tx.From.Hex(true), nonce)})
+		}
+	}
+	if len(dropped) == 0 {
+		return nil
+	}
+	return bc.TxsPoolDB.DropTxs(dropped)
+}
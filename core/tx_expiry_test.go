@@ -0,0 +1,110 @@
+package core
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestDropUnpackableTxs(t *testing.T) {
+	bc := newTestBlockchain(t, nil)
+	// nonce 0 of the first key is used on the main chain
+	err := bc.SendTransaction(newTestTx(t, bc, testKeys[0], 1, 0))
+	if err != nil {
+		t.Fatal(err)
+	}
+	mineTestBlock(t, bc)
+	const height, timestamp = 5, GenesisTimestamp + 1000
+	rules, err := bc.NextRules()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name       string
+		key        int
+		nonce      uint64
+		validUntil int64
+		reason     string // empty if the tx is kept
+	}{
+		{"never expires", 1, 0, 0, ""},
+		{"valid until the height", 1, 1, height, ""},
+		{"valid until the height before", 2, 0, height - 1, "expired at height 5"},
+		{"valid until the timestamp", 2, 1, timestamp, ""},
+		{"valid until a second before", 2, 2, timestamp - 1, "expired at"},
+		{"next nonce", 0, 1, 0, ""},
+		{"nonce already used", 0, 0, 0, "nonce 0 is already used"},
+	}
+	var txs []*Transaction
+	for _, test := range tests {
+		key := testKeys[test.key]
+		tx, err := NewTransaction(rules, testAddress(key), testMiner, "", 2, 0, test.validUntil, test.nonce)
+		if err != nil {
+			t.Fatal(err)
+		}
+		err = tx.Sign(key)
+		if err != nil {
+			t.Fatal(err)
+		}
+		txs = append(txs, tx)
+	}
+	// txs are put in pool directly, SendTransaction refuses the ones which can never be packaged
+	bc.TxsPoolDB.AddTxs(txs)
+	err = bc.dropUnpackableTxs(height, timestamp)
+	if err != nil {
+		t.Fatal(err)
+	}
+	pending := make(map[*Transaction]bool)
+	for _, tx := range bc.TxsPoolDB.GetAllTxs() {
+		pending[tx] = true
+	}
+	for i, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			tx := txs[i]
+			dropped, err := bc.TxsPoolDB.GetDroppedTx(tx.Hash)
+			if test.reason == "" {
+				if !pending[tx] || err == nil {
+					t.Fatalf("tx should be kept in pool, dropped: %v", err == nil)
+				}
+				return
+			}
+			if pending[tx] {
+				t.Fatal("tx should be dropped from pool")
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !strings.Contains(dropped.Reason, test.reason) {
+				t.Fatalf("reason %q should contain %q", dropped.Reason, test.reason)
+			}
+		})
+	}
+}
+
+// TestMineBlockDropsUsedNonce a tx whose nonce is used by a packaged tx is evicted instead of being kept forever
+func TestMineBlockDropsUsedNonce(t *testing.T) {
+	bc := newTestBlockchain(t, nil)
+	winner := newTestTx(t, bc, testKeys[0], 1, 0)
+	loser := newTestTx(t, bc, testKeys[0], 2, 0)
+	err := bc.SendTransaction(winner)
+	if err != nil {
+		t.Fatal(err)
+	}
+	mineTestBlock(t, bc)
+	// e.g. the loser of a replacement received from another node after the winner is packaged
+	bc.TxsPoolDB.AddTxs([]*Transaction{loser})
+	err = bc.SendTransaction(newTestTx(t, bc, testKeys[1], 1, 0))
+	if err != nil {
+		t.Fatal(err)
+	}
+	mineTestBlock(t, bc)
+	if pending := bc.TxsPoolDB.GetAllTxs(); len(pending) != 0 {
+		t.Fatalf("%v txs are left in pool", len(pending))
+	}
+	dropped, err := bc.TxsPoolDB.GetDroppedTx(loser.Hash)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(dropped.Reason, "already used") {
+		t.Fatalf("unexpected reason %q", dropped.Reason)
+	}
+}
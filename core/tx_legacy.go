@@ -0,0 +1,80 @@
+package core
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"fmt"
+	"github.com/XiaoYao-0/memory-blockchain/common"
+)
+
+// legacyTransaction the flat transaction stored in transactions.db before envelopes, gob matches its fields by name
+type legacyTransaction struct {
+	From   common.Address
+	To     common.Address
+	Data   []byte
+	Amount int64
+	Fee    int64
+	Hash   common.Hash
+}
+
+// upgrade wrap the fields of l in an envelope of TxLegacy, the hash stays valid
+func (l *legacyTransaction) upgrade() *Transaction {
+	return &Transaction{
+		Version: 0,
+		From:    l.From,
+		Fee:     l.Fee,
+		Payload: &LegacyPayload{To: l.To, Data: l.Data, Amount: l.Amount},
+		Hash:    l.Hash,
+	}
+}
+
+// LegacyPayload the fields of a legacy transaction besides the envelope
+type LegacyPayload struct {
+	To     common.Address
+	Data   []byte
+	Amount int64
+}
+
+func (p *LegacyPayload) Type() TxType  { return TxLegacy }
+func (p *LegacyPayload) amount() int64 { return p.Amount }
+func (p *LegacyPayload) data() []byte  { return p.Data }
+
+func (p *LegacyPayload) encode() []byte {
+	e := &payloadEncoder{}
+	e.address(p.To)
+	e.bytes(p.Data)
+	e.int64(p.Amount)
+	return e.buf.Bytes()
+}
+
+func (p *LegacyPayload) decode(d *payloadDecoder) {
+	p.To = d.address()
+	p.Data = d.bytes()
+	p.Amount = d.int64()
+}
+
+func (p *LegacyPayload) verify(rules *Rules) error {
+	return rules.verifyDataLength(p.Data)
+}
+
+func (p *LegacyPayload) exec(state State) error {
+	return credit(state, p.To, p.Amount, p.Data)
+}
+
+func (p *LegacyPayload) output() string {
+	return fmt.Sprintf("  To: %v\n  Data: %s\n  Amount: %v\n", p.To.Hex(true), p.Data, p.Amount)
+}
+
+// hash Hash = SHA256(From + To + Data + Amount + Fee) as before envelopes
+func (p *LegacyPayload) hash(tx *Transaction) common.Hash {
+	return sha256.Sum256(bytes.Join(
+		[][]byte{
+			tx.From.Bytes(),
+			p.To.Bytes(),
+			p.Data,
+			IntToHex(p.Amount),
+			IntToHex(tx.Fee),
+		},
+		[]byte{},
+	))
+}
@@ -0,0 +1,115 @@
+package core
+
+import (
+	"encoding/hex"
+	"github.com/XiaoYao-0/memory-blockchain/common"
+	"github.com/boltdb/bolt"
+	"path/filepath"
+	"reflect"
+	"testing"
+)
+
+// legacyTxFixture a transaction of 12345 with message "hello" from 0x0102… to 0x0a0b…, gob encoded by the
+// Transaction.Serialize of the baseline tree, before nonces, signatures and envelopes
+const legacyTxFixture = "507f0301010b5472616e73616374696f6e01ff80000106010446726f6d01ff82000102546f01ff8200010444617461010a" +
+	"000106416d6f756e74010400010346656501040001044861736801ff8400000017ff81010101074164647265737301ff820001060128" +
+	"000014ff83010101044861736801ff84000106014000006fff800114010200000000000000000000000000000000000001140a0b0000" +
+	"00000000000000000000000000000000010568656c6c6f01fe607201040120ffa55560ffb6ffe0ff9837ffd41dff87fff1ffb13b01ff" +
+	"944915ffe1ffca076bffd4602f2430ff8b53ff8effadfff2ffb900"
+
+const legacyTxFixtureHash = "0xa55560b6e09837d41d87f1b13b01944915e1ca076bd4602f24308b538eadf2b9"
+
+func decodeLegacyTxFixture(t *testing.T) []byte {
+	d, err := hex.DecodeString(legacyTxFixture)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return d
+}
+
+func TestDeserializeLegacyTransaction(t *testing.T) {
+	tx, err := DeserializeTransaction(decodeLegacyTxFixture(t))
+	if err != nil {
+		t.Fatal(err)
+	}
+	roundTrip, err := DeserializeTransaction(tx.Serialize())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name string
+		tx   *Transaction
+	}{
+		{"baseline encoding", tx},
+		{"envelope of the legacy type", roundTrip},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			want := &Transaction{
+				Version: 0,
+				From:    common.Address{0x01, 0x02},
+				Fee:     2,
+				Payload: &LegacyPayload{To: common.Address{0x0a, 0x0b}, Data: []byte("hello"), Amount: 12345},
+				Hash:    test.tx.Hash,
+			}
+			if !reflect.DeepEqual(test.tx, want) {
+				t.Fatalf("decoded %+v, want %+v", test.tx, want)
+			}
+			if test.tx.Hash.Hex(true) != legacyTxFixtureHash {
+				t.Fatalf("hash %v, want %v", test.tx.Hash.Hex(true), legacyTxFixtureHash)
+			}
+			if test.tx.CalcHash() != test.tx.Hash {
+				t.Fatalf("calculated hash %v differs from the stored one", test.tx.CalcHash().Hex(true))
+			}
+		})
+	}
+}
+
+func TestImportLegacyTransactions(t *testing.T) {
+	file := filepath.Join(t.TempDir(), "transactions.db")
+	legacyDB, err := bolt.Open(file, 0666, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	hash, err := common.NewHash(legacyTxFixtureHash)
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = legacyDB.Update(func(tx *bolt.Tx) error {
+		b, err := tx.CreateBucket([]byte(TransactionsBucket))
+		if err != nil {
+			return err
+		}
+		return b.Put(hash.Serialize(), decodeLegacyTxFixture(t))
+	})
+	_ = legacyDB.Close()
+	if err != nil {
+		t.Fatal(err)
+	}
+	db, err := bolt.Open(filepath.Join(t.TempDir(), "chain.db"), 0666, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { _ = db.Close() })
+	transactionsDB, err := NewTransactionsDB(db)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for i, want := range []int{1, 0} {
+		imported, err := transactionsDB.ImportLegacyTransactions(file)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if imported != want {
+			t.Fatalf("import %v copied %v transactions, want %v", i, imported, want)
+		}
+	}
+	tx, err := transactionsDB.GetTransaction(hash)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if tx.Type() != TxLegacy || tx.CalcHash() != hash {
+		t.Fatalf("imported transaction of type %v has hash %v", tx.Type(), tx.CalcHash().Hex(true))
+	}
+}
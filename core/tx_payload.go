@@ -0,0 +1,248 @@
+package core
+
+import (
+	"bytes"
+	"encoding/binary"
+	"fmt"
+	"github.com/XiaoYao-0/memory-blockchain/common"
+)
+
+// TxType tag of the payload in a transaction envelope, new kinds of transactions get new tags
+type TxType uint8
+
+const (
+	TxLegacy        TxType = iota // flat transaction stored before envelopes
+	TxTransfer                    // coins to an address
+	TxMessage                     // a message and optionally coins to an address
+	TxCoinbase                    // reward of a block to its miner
+	TxMultiTransfer               // coins and messages to a list of addresses
+)
+
+func (t TxType) String() string {
+	switch t {
+	case TxLegacy:
+		return "legacy"
+	case TxTransfer:
+		return "transfer"
+	case TxMessage:
+		return "message"
+	case TxCoinbase:
+		return "coinbase"
+	case TxMultiTransfer:
+		return "multitransfer"
+	default:
+		return fmt.Sprintf("unknown(%d)", uint8(t))
+	}
+}
+
+// TxPayload the part of a transaction specific to its type
+type TxPayload interface {
+	Type() TxType
+	// amount coins paid by the sender besides the fee
+	amount() int64
+	// data message bytes, the fee is charged on their length
+	data() []byte
+	// encode the canonical encoding which is hashed and stored
+	encode() []byte
+	decode(d *payloadDecoder)
+	// verify check the payload under rules, the fee is checked by Rules.VerifyTransaction
+	verify(rules *Rules) error
+	// exec credit the receivers, the sender has been charged by the envelope
+	exec(state State) error
+	// output lines of Transaction.Output
+	output() string
+}
+
+// decodePayload dispatch the canonical encoding to the payload of type t
+func decodePayload(t TxType, data []byte) (TxPayload, error) {
+	var payload TxPayload
+	switch t {
+	case TxLegacy:
+		payload = &LegacyPayload{}
+	case TxTransfer:
+		payload = &TransferPayload{}
+	case TxMessage:
+		payload = &MessagePayload{}
+	case TxCoinbase:
+		payload = &Coinbase{}
+	case TxMultiTransfer:
+		payload = &MultiTransferPayload{}
+	default:
+		return nil, fmt.Errorf("decodePayload error: unknown transaction type %v", t)
+	}
+	d := &payloadDecoder{data: data}
+	payload.decode(d)
+	err := d.finish()
+	if err != nil {
+		return nil, fmt.Errorf("decodePayload error: %v payload: %v", t, err)
+	}
+	return payload, nil
+}
+
+// TransferPayload transfer Amount to To
+type TransferPayload struct {
+	To     common.Address
+	Amount int64
+}
+
+func (p *TransferPayload) Type() TxType  { return TxTransfer }
+func (p *TransferPayload) amount() int64 { return p.Amount }
+func (p *TransferPayload) data() []byte  { return nil }
+
+func (p *TransferPayload) encode() []byte {
+	e := &payloadEncoder{}
+	e.address(p.To)
+	e.int64(p.Amount)
+	return e.buf.Bytes()
+}
+
+func (p *TransferPayload) decode(d *payloadDecoder) {
+	p.To = d.address()
+	p.Amount = d.int64()
+}
+
+func (p *TransferPayload) verify(rules *Rules) error {
+	return nil
+}
+
+func (p *TransferPayload) exec(state State) error {
+	return credit(state, p.To, p.Amount, nil)
+}
+
+func (p *TransferPayload) output() string {
+	return fmt.Sprintf("  To: %v\n  Amount: %v\n", p.To.Hex(true), p.Amount)
+}
+
+// MessagePayload leave Data in the account of To with Amount, which may be 0
+type MessagePayload struct {
+	To     common.Address
+	Data   []byte
+	Amount int64
+}
+
+func (p *MessagePayload) Type() TxType  { return TxMessage }
+func (p *MessagePayload) amount() int64 { return p.Amount }
+func (p *MessagePayload) data() []byte  { return p.Data }
+
+func (p *MessagePayload) encode() []byte {
+	e := &payloadEncoder{}
+	e.address(p.To)
+	e.bytes(p.Data)
+	e.int64(p.Amount)
+	return e.buf.Bytes()
+}
+
+func (p *MessagePayload) decode(d *payloadDecoder) {
+	p.To = d.address()
+	p.Data = d.bytes()
+	p.Amount = d.int64()
+}
+
+func (p *MessagePayload) verify(rules *Rules) error {
+	return rules.verifyDataLength(p.Data)
+}
+
+func (p *MessagePayload) exec(state State) error {
+	return credit(state, p.To, p.Amount, p.Data)
+}
+
+func (p *MessagePayload) output() string {
+	return fmt.Sprintf("  To: %v\n  Data: %s\n  Amount: %v\n", p.To.Hex(true), p.Data, p.Amount)
+}
+
+// credit add amount and message to the account of addr
+func credit(state State, addr common.Address, amount int64, message []byte) error {
+	account, err := state.GetAccount(addr)
+	if err != nil {
+		return err
+	}
+	if account.Balance+amount < account.Balance {
+		return fmt.Errorf("integer overflow: %v+%v->%v", account.Balance, amount, account.Balance+amount)
+	}
+	account.Balance += amount
+	if len(message) != 0 {
+		account.Messages = append(account.Messages, message)
+	}
+	return state.PutAccount(account)
+}
+
+// payloadEncoder the canonical encoding of payloads: fixed size big-endian integers, raw addresses
+// and length-prefixed bytes
+type payloadEncoder struct {
+	buf bytes.Buffer
+}
+
+func (e *payloadEncoder) uint8(n uint8) {
+	e.buf.WriteByte(n)
+}
+
+func (e *payloadEncoder) int64(n int64) {
+	var b [8]byte
+	binary.BigEndian.PutUint64(b[:], uint64(n))
+	e.buf.Write(b[:])
+}
+
+func (e *payloadEncoder) address(addr common.Address) {
+	e.buf.Write(addr.Bytes())
+}
+
+func (e *payloadEncoder) bytes(b []byte) {
+	e.int64(int64(len(b)))
+	e.buf.Write(b)
+}
+
+// payloadDecoder read what payloadEncoder writes, the first error is kept and reported by finish
+type payloadDecoder struct {
+	data []byte
+	err  error
+}
+
+func (d *payloadDecoder) next(n int64) []byte {
+	if d.err != nil {
+		return nil
+	}
+	if n < 0 || n > int64(len(d.data)) {
+		d.err = fmt.Errorf("encoding is truncated")
+		return nil
+	}
+	b := d.data[:n]
+	d.data = d.data[n:]
+	return b
+}
+
+func (d *payloadDecoder) uint8() uint8 {
+	b := d.next(1)
+	if b == nil {
+		return 0
+	}
+	return b[0]
+}
+
+func (d *payloadDecoder) int64() int64 {
+	b := d.next(8)
+	if b == nil {
+		return 0
+	}
+	return int64(binary.BigEndian.Uint64(b))
+}
+
+func (d *payloadDecoder) address() common.Address {
+	var addr common.Address
+	copy(addr[:], d.next(int64(len(addr))))
+	return addr
+}
+
+func (d *payloadDecoder) bytes() []byte {
+	b := d.next(d.int64())
+	if len(b) == 0 {
+		return nil
+	}
+	return append([]byte{}, b...)
+}
+
+func (d *payloadDecoder) finish() error {
+	if d.err == nil && len(d.data) != 0 {
+		d.err = fmt.Errorf("%v trailing bytes", len(d.data))
+	}
+	return d.err
+}
@@ -2,30 +2,35 @@ package core
 
 import (
 	"fmt"
+	"github.com/XiaoYao-0/memory-blockchain/common"
 	"github.com/boltdb/bolt"
+	"sync"
 )
 
 const (
-	TxsPoolDBFile      = "./data/txs_pool.db"
-	TxsPoolBucket      = "blocks_bucket"
+	TxsPoolBucket      = "txs_pool_bucket"
 	TxsPoolKey         = "txs_pool"
+	DroppedTxsBucket   = "dropped_txs_bucket" // hash of transaction -> DroppedTx
 	MaxRetryOfFlushing = 5
 )
 
+// TxsPoolDB the TxsPool in memory and its copy in the bolt file, it is safe for concurrent use
 type TxsPoolDB struct {
-	TxsPool *TxsPool
-	DB      *bolt.DB
+	TxsPool  *TxsPool // guarded by mu, use the methods of TxsPoolDB
+	DB       *bolt.DB
+	mu       sync.Mutex
+	flushMu  sync.Mutex     // one flush writes at a time
+	flushing sync.WaitGroup // flushes not written yet
 }
 
-func NewTxsPoolDB() (*TxsPoolDB, error) {
-	db, err := bolt.Open(TxsPoolDBFile, 0666, nil)
-	if err != nil {
-		return nil, fmt.Errorf("NewTxsPoolDB error: %v", err)
-	}
+func NewTxsPoolDB(db *bolt.DB) (*TxsPoolDB, error) {
 	txsPool := &TxsPool{Txs: []*Transaction{}}
-	err = db.Update(func(tx *bolt.Tx) error {
+	err := db.Update(func(tx *bolt.Tx) error {
+		_, txError := tx.CreateBucketIfNotExists([]byte(DroppedTxsBucket))
+		if txError != nil {
+			return txError
+		}
 		b := tx.Bucket([]byte(TxsPoolBucket))
-		var txError error
 		if b == nil {
 			b, txError = tx.CreateBucket([]byte(TxsPoolBucket))
 			if txError != nil {
@@ -53,32 +58,111 @@ func NewTxsPoolDB() (*TxsPoolDB, error) {
 	}, nil
 }
 
+// GetAllTxs a copy of the txs in pool
 func (db *TxsPoolDB) GetAllTxs() []*Transaction {
-	return db.TxsPool.getAllTxs()
+	db.mu.Lock()
+	defer db.mu.Unlock()
+	return append([]*Transaction{}, db.TxsPool.getAllTxs()...)
 }
 
-// GetSomeTxs please use DefaultNumberOfTxsInBlock
-func (db *TxsPoolDB) GetSomeTxs(number int) []*Transaction {
-	return db.TxsPool.getSomeTxs(number)
+// GetTxsWithin txs to fill a block, see ChainConfig.MaxBlockBytes and ChainConfig.MaxBlockDataBytes
+func (db *TxsPoolDB) GetTxsWithin(maxBytes, maxDataBytes int64) []*Transaction {
+	db.mu.Lock()
+	defer db.mu.Unlock()
+	return db.TxsPool.getTxsWithin(maxBytes, maxDataBytes)
 }
 
 func (db *TxsPoolDB) AddTxs(txs []*Transaction) {
+	db.mu.Lock()
 	db.TxsPool.addTxs(txs)
+	db.mu.Unlock()
 	db.flush()
 }
 
 func (db *TxsPoolDB) LeftAddTxs(txs []*Transaction) {
+	db.mu.Lock()
 	db.TxsPool.leftAddTxs(txs)
+	db.mu.Unlock()
 	db.flush()
 }
 
-func (db *TxsPoolDB) DeleteSomeTxs(number int) {
-	db.TxsPool.deleteSomeTxs(number)
+// RemoveTxs remove txs by hash, e.g. when they are packaged
+func (db *TxsPoolDB) RemoveTxs(hashes []common.Hash) {
+	db.mu.Lock()
+	db.TxsPool.removeTxs(hashes)
+	db.mu.Unlock()
 	db.flush()
 }
 
+func (db *TxsPoolDB) Output() string {
+	db.mu.Lock()
+	defer db.mu.Unlock()
+	return db.TxsPool.Output()
+}
+
+// DropTxs remove txs from pool for good and record why, see GetDroppedTx
+func (db *TxsPoolDB) DropTxs(dropped []*DroppedTx) error {
+	hashes := make([]common.Hash, len(dropped))
+	for i, d := range dropped {
+		hashes[i] = d.Tx.Hash
+	}
+	db.RemoveTxs(hashes)
+	err := db.DB.Update(func(tx *bolt.Tx) error {
+		b := tx.Bucket([]byte(DroppedTxsBucket))
+		if b == nil {
+			return fmt.Errorf("bucket %v do not exist", DroppedTxsBucket)
+		}
+		for _, d := range dropped {
+			txError := b.Put(d.Tx.Hash.Serialize(), d.Serialize())
+			if txError != nil {
+				return txError
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		return fmt.Errorf("DropTxs error: %v", err)
+	}
+	return nil
+}
+
+// GetDroppedTx the transaction dropped from pool and the reason
+func (db *TxsPoolDB) GetDroppedTx(hash common.Hash) (*DroppedTx, error) {
+	var dropped *DroppedTx
+	err := db.DB.View(func(tx *bolt.Tx) error {
+		b := tx.Bucket([]byte(DroppedTxsBucket))
+		if b == nil {
+			return fmt.Errorf("bucket %v do not exist", DroppedTxsBucket)
+		}
+		encoded := b.Get(hash.Serialize())
+		if encoded == nil {
+			return fmt.Errorf("transaction %v is not dropped", hash.Hex(true))
+		}
+		var txError error
+		dropped, txError = DeserializeDroppedTx(encoded)
+		return txError
+	})
+	if err != nil {
+		return nil, fmt.Errorf("GetDroppedTx error: %v", err)
+	}
+	return dropped, nil
+}
+
+// Wait block until every flush is written, the bolt file should not be closed before
+func (db *TxsPoolDB) Wait() {
+	db.flushing.Wait()
+}
+
+// flush write the pool in the background, every flush writes the latest pool so the last write is never stale
 func (db *TxsPoolDB) flush() {
+	db.flushing.Add(1)
 	go func() {
+		defer db.flushing.Done()
+		db.flushMu.Lock()
+		defer db.flushMu.Unlock()
+		db.mu.Lock()
+		encodedTxsPool := db.TxsPool.Serialize()
+		db.mu.Unlock()
 		var err error
 		for i := 0; i < MaxRetryOfFlushing; i++ {
 			err = db.DB.Update(func(tx *bolt.Tx) error {
@@ -87,7 +171,7 @@ func (db *TxsPoolDB) flush() {
 				if b == nil {
 					return fmt.Errorf("bucket %v do not exist", TxsPoolBucket)
 				}
-				txError = b.Put([]byte(TxsPoolKey), db.TxsPool.Serialize())
+				txError = b.Put([]byte(TxsPoolKey), encodedTxsPool)
 				if txError != nil {
 					return txError
 				}
@@ -0,0 +1,173 @@
+package core
+
+import (
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"github.com/XiaoYao-0/memory-blockchain/common"
+)
+
+// TxProof proves that a transaction is packaged in a block to anyone who trusts the block hash,
+// it can be verified offline by VerifyTxProof
+type TxProof struct {
+	TxHash common.Hash
+	Header BlockHeader
+	Path   []MerkleStep
+}
+
+// NewTxProof build the proof of txHash in block
+func NewTxProof(block *Block, txHash common.Hash) (*TxProof, error) {
+	index := -1
+	for i, tx := range block.Txs {
+		if tx.Hash == txHash {
+			index = i
+			break
+		}
+	}
+	if index == -1 {
+		return nil, fmt.Errorf("NewTxProof error: transaction %v is not in block %v", txHash.Hex(true), block.Hash.Hex(true))
+	}
+	path, err := NewMerkleTree(block.TxsHashes()).Path(index)
+	if err != nil {
+		return nil, fmt.Errorf("NewTxProof error: %v", err)
+	}
+	return &TxProof{
+		TxHash: txHash,
+		Header: block.Header,
+		Path:   path,
+	}, nil
+}
+
+// BlockHash the hash of the block the proof commits to
+func (p *TxProof) BlockHash() common.Hash {
+	return p.Header.Hash()
+}
+
+// VerifyTxProof check that the proof leads from the transaction to trustedBlockHash
+func VerifyTxProof(p *TxProof, trustedBlockHash common.Hash) error {
+	if p.BlockHash() != trustedBlockHash {
+		return fmt.Errorf("VerifyTxProof error: proof is for block %v instead of %v", p.BlockHash().Hex(true), trustedBlockHash.Hex(true))
+	}
+	root := MerkleRootFromPath(p.TxHash, p.Path)
+	if root != p.Header.MerkleRoot {
+		return fmt.Errorf("VerifyTxProof error: computed Merkle root %v does not match %v in header",
+			root.Hex(true), p.Header.MerkleRoot.Hex(true))
+	}
+	return nil
+}
+
+func (p *TxProof) Output() string {
+	return fmt.Sprintf("TxProof of %v\n"+
+		"  Block: %v\n"+
+		"  Height: %v\n"+
+		"  MerkleRoot: %v\n"+
+		"  PathLength: %v\n",
+		p.TxHash.Hex(true),
+		p.BlockHash().Hex(true),
+		p.Header.Height,
+		p.Header.MerkleRoot.Hex(true),
+		len(p.Path))
+}
+
+type merkleStepJSON struct {
+	Hash string `json:"hash"`
+	Left bool   `json:"left"`
+}
+
+type blockHeaderJSON struct {
+	Version       int32  `json:"version"`
+	Height        int64  `json:"height"`
+	Timestamp     int64  `json:"timestamp"`
+	PrevBlockHash string `json:"prevBlockHash"`
+	MerkleRoot    string `json:"merkleRoot"`
+	Bits          int64  `json:"bits"`
+	Nonce         int64  `json:"nonce"`
+	Miner         string `json:"miner"`
+	Seal          string `json:"seal"`
+}
+
+type txProofJSON struct {
+	TxHash    string           `json:"txHash"`
+	BlockHash string           `json:"blockHash"`
+	Header    blockHeaderJSON  `json:"header"`
+	Path      []merkleStepJSON `json:"path"`
+}
+
+func (p *TxProof) MarshalJSON() ([]byte, error) {
+	pj := txProofJSON{
+		TxHash:    p.TxHash.Hex(true),
+		BlockHash: p.BlockHash().Hex(true),
+		Header: blockHeaderJSON{
+			Version:       p.Header.Version,
+			Height:        p.Header.Height,
+			Timestamp:     p.Header.Timestamp,
+			PrevBlockHash: p.Header.PrevBlockHash.Hex(true),
+			MerkleRoot:    p.Header.MerkleRoot.Hex(true),
+			Bits:          p.Header.Bits,
+			Nonce:         p.Header.Nonce,
+			Miner:         p.Header.Miner.Hex(true),
+			Seal:          hex.EncodeToString(p.Header.Seal),
+		},
+		Path: make([]merkleStepJSON, len(p.Path)),
+	}
+	for i, step := range p.Path {
+		pj.Path[i] = merkleStepJSON{Hash: step.Hash.Hex(true), Left: step.Left}
+	}
+	return json.MarshalIndent(pj, "", "  ")
+}
+
+func (p *TxProof) UnmarshalJSON(data []byte) error {
+	var pj txProofJSON
+	err := json.Unmarshal(data, &pj)
+	if err != nil {
+		return err
+	}
+	txHash, err := common.NewHash(pj.TxHash)
+	if err != nil {
+		return fmt.Errorf("illegal txHash: %v", err)
+	}
+	prevBlockHash, err := common.NewHash(pj.Header.PrevBlockHash)
+	if err != nil {
+		return fmt.Errorf("illegal prevBlockHash: %v", err)
+	}
+	merkleRoot, err := common.NewHash(pj.Header.MerkleRoot)
+	if err != nil {
+		return fmt.Errorf("illegal merkleRoot: %v", err)
+	}
+	miner, err := common.NewAddress(pj.Header.Miner)
+	if err != nil {
+		return fmt.Errorf("illegal miner: %v", err)
+	}
+	seal, err := hex.DecodeString(pj.Header.Seal)
+	if err != nil {
+		return fmt.Errorf("illegal seal: %v", err)
+	}
+	if len(seal) == 0 {
+		seal = nil
+	}
+	path := make([]MerkleStep, len(pj.Path))
+	for i, step := range pj.Path {
+		hash, err := common.NewHash(step.Hash)
+		if err != nil {
+			return fmt.Errorf("illegal hash in path: %v", err)
+		}
+		path[i] = MerkleStep{Hash: hash, Left: step.Left}
+	}
+	p.TxHash = txHash
+	p.Header = BlockHeader{
+		Version:       pj.Header.Version,
+		Height:        pj.Header.Height,
+		Timestamp:     pj.Header.Timestamp,
+		PrevBlockHash: prevBlockHash,
+		MerkleRoot:    merkleRoot,
+		Bits:          pj.Header.Bits,
+		Nonce:         pj.Header.Nonce,
+		Miner:         miner,
+		Seal:          seal,
+	}
+	p.Path = path
+	if pj.BlockHash != "" && pj.BlockHash != p.BlockHash().Hex(true) {
+		return fmt.Errorf("header does not match blockHash %v", pj.BlockHash)
+	}
+	return nil
+}
@@ -4,13 +4,11 @@ import (
 	"bytes"
 	"encoding/gob"
 	"fmt"
+	"github.com/XiaoYao-0/memory-blockchain/common"
+	"sort"
 	"strings"
 )
 
-const (
-	DefaultNumberOfTxsInBlock = 10
-)
-
 type TxsPool struct {
 	Txs []*Transaction
 }
@@ -19,12 +17,47 @@ func (p *TxsPool) getAllTxs() []*Transaction {
 	return p.Txs
 }
 
-// GetSomeTxs please use DefaultNumberOfTxsInBlock
-func (p *TxsPool) getSomeTxs(number int) []*Transaction {
-	if len(p.Txs) <= number {
-		return p.getAllTxs()
+// getTxsWithin txs which fit in maxBytes of serialized size and maxDataBytes of messages, higher fees per byte first.
+// Txs of a sender stay in order of nonces, and a tx too large for the space left is skipped with the later txs
+// of its sender so that txs of others can fill the block
+func (p *TxsPool) getTxsWithin(maxBytes, maxDataBytes int64) []*Transaction {
+	// queues of txs in order of nonces by sender, senders in order of their first tx in the pool
+	queues := make(map[common.Address][]*Transaction)
+	var senders []common.Address
+	sizes := make(map[*Transaction]int64)
+	for _, tx := range p.Txs {
+		sizes[tx] = tx.Size()
+		if _, ok := queues[tx.From]; !ok {
+			senders = append(senders, tx.From)
+		}
+		queues[tx.From] = append(queues[tx.From], tx)
+	}
+	for _, queue := range queues {
+		sort.SliceStable(queue, func(i, j int) bool { return queue[i].Nonce < queue[j].Nonce })
+	}
+	var txs []*Transaction
+	for {
+		// the next tx offering the highest fee per byte, the earlier sender in the pool on ties
+		var best *Transaction
+		for _, sender := range senders {
+			queue := queues[sender]
+			if len(queue) != 0 && (best == nil || higherFeeRate(queue[0].Fee, sizes[queue[0]], best.Fee, sizes[best])) {
+				best = queue[0]
+			}
+		}
+		if best == nil {
+			return txs
+		}
+		size, dataSize := sizes[best], int64(len(best.Data()))
+		if size > maxBytes || dataSize > maxDataBytes {
+			queues[best.From] = nil
+			continue
+		}
+		txs = append(txs, best)
+		queues[best.From] = queues[best.From][1:]
+		maxBytes -= size
+		maxDataBytes -= dataSize
 	}
-	return p.Txs[:number]
 }
 
 func (p *TxsPool) addTxs(txs []*Transaction) {
@@ -35,12 +68,18 @@ func (p *TxsPool) leftAddTxs(txs []*Transaction) {
 	p.Txs = append(txs, p.Txs...)
 }
 
-func (p *TxsPool) deleteSomeTxs(number int) {
-	if len(p.Txs) <= number {
-		p.Txs = []*Transaction{}
-		return
+func (p *TxsPool) removeTxs(hashes []common.Hash) {
+	removed := make(map[common.Hash]bool)
+	for _, hash := range hashes {
+		removed[hash] = true
+	}
+	txs := []*Transaction{}
+	for _, tx := range p.Txs {
+		if !removed[tx.Hash] {
+			txs = append(txs, tx)
+		}
 	}
-	p.Txs = p.Txs[number:]
+	p.Txs = txs
 }
 
 func (p *TxsPool) Output() string {
@@ -66,7 +105,7 @@ func DeserializeTxsPool(d []byte) (*TxsPool, error) {
 	decoder := gob.NewDecoder(bytes.NewReader(d))
 	err := decoder.Decode(&txsPool)
 	if err != nil {
-		return nil, fmt.Errorf("DeserializeBlock error: %v", err)
+		return nil, fmt.Errorf("DeserializeTxsPool error: %v", err)
 	}
 	return &txsPool, nil
 }
@@ -0,0 +1,233 @@
+package core
+
+import (
+	"fmt"
+	"github.com/XiaoYao-0/memory-blockchain/common"
+	"github.com/boltdb/bolt"
+	"time"
+)
+
+// Verify walk the chain from genesis to tip and check prev-hash links, heights and the height index,
+// headers by the consensus engine, timestamps, transaction hashes and signatures, Merkle roots, transaction and supply records.
+// Every block is re-executed on a fresh state built from genesis and the result is compared with AccountsDB.
+// The first inconsistency is returned.
+// It returns the number of verified blocks after genesis.
+func (bc *Blockchain) Verify() (int, error) {
+	var count int
+	err := bc.DB.View(func(tx *bolt.Tx) error {
+		hashes, txError := mainChainHashes(tx)
+		if txError != nil {
+			return txError
+		}
+		blocks := tx.Bucket([]byte(BlocksBucket))
+		if blocks == nil {
+			return fmt.Errorf("bucket %v do not exist", BlocksBucket)
+		}
+		state := NewMemoryState()
+		for _, account := range initAccounts(bc.Genesis) {
+			txError = state.PutAccount(account)
+			if txError != nil {
+				return txError
+			}
+		}
+		var parent *Block
+		supply := &Supply{}
+		now := bc.BlocksDB.Clock()
+		for _, hash := range hashes {
+			encodedBlock := blocks.Get(hash.Serialize())
+			if encodedBlock == nil {
+				return fmt.Errorf("block %v do not exist", hash.Hex(true))
+			}
+			block, txError := DeserializeBlock(encodedBlock)
+			if txError != nil {
+				return fmt.Errorf("block %v: %v", hash.Hex(true), txError)
+			}
+			if parent == nil {
+				if block.Hash != NewGenesisBlock().Hash || block.Header.Hash() != block.Hash {
+					return fmt.Errorf("genesis block %v does not match the expected %v", block.Hash.Hex(true), NewGenesisBlock().Hash.Hex(true))
+				}
+				parent = block
+				continue
+			}
+			txError = bc.verifyBlock(tx, state, block, parent, now)
+			if txError == nil {
+				supply = supply.next(block, bc.Engine.CalculateReward(&block.Header, block.Fees()))
+				txError = verifySupply(tx, block.Hash, supply)
+			}
+			if txError != nil {
+				return fmt.Errorf("block %v at height %v: %v", block.Hash.Hex(true), block.Header.Height, txError)
+			}
+			parent = block
+			count++
+		}
+		return verifyState(tx, state)
+	})
+	if err != nil {
+		return count, fmt.Errorf("Verify error: %v", err)
+	}
+	return count, nil
+}
+
+// mainChainHashes hashes of blocks from genesis to tip
+func mainChainHashes(tx *bolt.Tx) ([]common.Hash, error) {
+	tip, err := getTip(tx)
+	if err != nil {
+		return nil, fmt.Errorf("illegal %v: %v", LastBlockHash, err)
+	}
+	var hashes []common.Hash
+	hash := tip
+	for {
+		header, err := getHeader(tx, hash)
+		if err != nil {
+			return nil, err
+		}
+		hashes = append(hashes, hash)
+		if header.Height == 0 {
+			break
+		}
+		hash = header.PrevBlockHash
+	}
+	for i, j := 0, len(hashes)-1; i < j; i, j = i+1, j-1 {
+		hashes[i], hashes[j] = hashes[j], hashes[i]
+	}
+	return hashes, nil
+}
+
+func (bc *Blockchain) verifyBlock(tx *bolt.Tx, state *MemoryState, block, parent *Block, now time.Time) error {
+	header := &block.Header
+	if block.Hash != header.Hash() {
+		return fmt.Errorf("hash does not match the header")
+	}
+	indexedHash, err := getHashByHeight(tx, header.Height)
+	if err != nil {
+		return err
+	}
+	if indexedHash != block.Hash {
+		return fmt.Errorf("height index points to %v", indexedHash.Hex(true))
+	}
+	storedHeader, err := getHeader(tx, block.Hash)
+	if err != nil {
+		return err
+	}
+	if storedHeader.Hash() != block.Hash {
+		return fmt.Errorf("stored header does not match the block")
+	}
+	if header.PrevBlockHash != parent.Hash {
+		return fmt.Errorf("previous block %v is not %v", header.PrevBlockHash.Hex(true), parent.Hash.Hex(true))
+	}
+	if header.Height != parent.Header.Height+1 {
+		return fmt.Errorf("height=%v, expected %v", header.Height, parent.Header.Height+1)
+	}
+	err = bc.Config.verifyCheckpoint(header.Height, block.Hash)
+	if err != nil {
+		return err
+	}
+	err = verifyTimestamp(&txHeaderReader{tx}, bc.Config, header, &parent.Header, now)
+	if err != nil {
+		return err
+	}
+	err = bc.Engine.VerifyHeader(&txHeaderReader{tx}, header, &parent.Header)
+	if err != nil {
+		return err
+	}
+	err = block.CheckWeight(bc.Config)
+	if err != nil {
+		return err
+	}
+	seen := make(map[common.Hash]bool)
+	for _, transaction := range block.Txs {
+		if seen[transaction.Hash] {
+			return fmt.Errorf("transaction %v is packaged twice", transaction.Hash.Hex(true))
+		}
+		seen[transaction.Hash] = true
+		if transaction.CalcHash() != transaction.Hash {
+			return fmt.Errorf("hash of transaction %v does not match its content", transaction.Hash.Hex(true))
+		}
+		err = verifyTransactionRecord(tx, transaction, block.Hash)
+		if err != nil {
+			return err
+		}
+	}
+	if MerkleRoot(block.TxsHashes()) != header.MerkleRoot {
+		return fmt.Errorf("Merkle root of transactions does not match %v", header.MerkleRoot.Hex(true))
+	}
+	txState := NewCachedState(state)
+	err = block.Exec(txState, bc.Config.Rules(header.Height), bc.Engine)
+	if err != nil {
+		return err
+	}
+	return txState.Commit()
+}
+
+func verifyTransactionRecord(tx *bolt.Tx, transaction *Transaction, blockHash common.Hash) error {
+	b := tx.Bucket([]byte(TransactionsBucket))
+	lb := tx.Bucket([]byte(TxLookupBucket))
+	if b == nil || lb == nil {
+		return fmt.Errorf("bucket %v or %v do not exist", TransactionsBucket, TxLookupBucket)
+	}
+	encodedTransaction := b.Get(transaction.Hash.Serialize())
+	if encodedTransaction == nil {
+		return fmt.Errorf("transaction %v is not in %v", transaction.Hash.Hex(true), TransactionsBucket)
+	}
+	stored, err := DeserializeTransaction(encodedTransaction)
+	if err != nil {
+		return err
+	}
+	if stored.CalcHash() != transaction.Hash {
+		return fmt.Errorf("stored transaction %v does not match the block", transaction.Hash.Hex(true))
+	}
+	lookup, err := common.DeserializeHash(lb.Get(transaction.Hash.Serialize()))
+	if err != nil || lookup != blockHash {
+		return fmt.Errorf("transaction %v is not indexed to this block", transaction.Hash.Hex(true))
+	}
+	return nil
+}
+
+func verifySupply(tx *bolt.Tx, blockHash common.Hash, supply *Supply) error {
+	stored, err := getSupply(tx, blockHash)
+	if err != nil {
+		return err
+	}
+	if stored.Height != supply.Height || stored.Minted != supply.Minted || stored.Burned != supply.Burned {
+		return fmt.Errorf("supply record (minted %v, burned %v) does not match the re-executed chain (minted %v, burned %v)",
+			stored.Minted, stored.Burned, supply.Minted, supply.Burned)
+	}
+	return nil
+}
+
+// verifyState compare the re-executed state with the accounts bucket in both directions
+func verifyState(tx *bolt.Tx, state *MemoryState) error {
+	b := tx.Bucket([]byte(AccountsBucket))
+	if b == nil {
+		return fmt.Errorf("bucket %v do not exist", AccountsBucket)
+	}
+	stored := &bucketState{bucket: b}
+	for _, account := range state.Accounts() {
+		storedAccount, err := stored.GetAccount(account.Address)
+		if err != nil {
+			return err
+		}
+		if !storedAccount.Equal(account) {
+			return fmt.Errorf("account %v in %v does not match the re-executed chain:\nstored %vexpected %v",
+				account.Address.Hex(true), AccountsBucket, storedAccount.Output(), account.Output())
+		}
+	}
+	return b.ForEach(func(k, v []byte) error {
+		addr, err := common.DeserializeAddress(k)
+		if err != nil {
+			return fmt.Errorf("illegal key %x in %v", k, AccountsBucket)
+		}
+		account, err := state.GetAccount(addr)
+		if err != nil {
+			return err
+		}
+		storedAccount, err := DeserializeAccount(v)
+		if err != nil {
+			return err
+		}
+		if !storedAccount.Equal(account) {
+			return fmt.Errorf("account %v in %v is not produced by the chain", addr.Hex(true), AccountsBucket)
+		}
+		return nil
+	})
+}
@@ -3,8 +3,10 @@ module github.com/XiaoYao-0/memory-blockchain
 go 1.16
 
 require (
-	github.com/boltdb/bolt v1.3.1 // indirect
-	github.com/c-bata/go-prompt v0.2.6 // indirect
-	github.com/urfave/cli/v2 v2.3.0 // indirect
+	github.com/boltdb/bolt v1.3.1
+	github.com/c-bata/go-prompt v0.2.6
+	github.com/urfave/cli/v2 v2.3.0
+	golang.org/x/crypto v0.0.0-20220315160706-3147a52a75dd
 	golang.org/x/sys v0.0.0-20220310020820-b874c991c1a5 // indirect
+	golang.org/x/term v0.0.0-20210927222741-03fcf44c2211
 )
@@ -0,0 +1,166 @@
+package keystore
+
+import (
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/ecdsa"
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"github.com/XiaoYao-0/memory-blockchain/common"
+	"golang.org/x/crypto/scrypt"
+)
+
+const (
+	KeyFileVersion = 1
+	CipherName     = "aes-256-gcm"
+	KDFName        = "scrypt"
+
+	// StandardScryptN StandardScryptR StandardScryptP scrypt parameters, about 100ms and 32MB per derivation
+	StandardScryptN = 1 << 15
+	StandardScryptR = 8
+	StandardScryptP = 1
+	ScryptDKLen     = 32
+	SaltLength      = 32
+)
+
+var ErrDecrypt = errors.New("could not decrypt key with given passphrase")
+
+type kdfParamsJSON struct {
+	N     int    `json:"n"`
+	R     int    `json:"r"`
+	P     int    `json:"p"`
+	DKLen int    `json:"dklen"`
+	Salt  string `json:"salt"`
+}
+
+type cryptoJSON struct {
+	Cipher     string        `json:"cipher"`
+	CipherText string        `json:"ciphertext"`
+	Nonce      string        `json:"nonce"`
+	KDF        string        `json:"kdf"`
+	KDFParams  kdfParamsJSON `json:"kdfparams"`
+}
+
+type keyFileJSON struct {
+	Address string     `json:"address"`
+	Crypto  cryptoJSON `json:"crypto"`
+	Version int        `json:"version"`
+}
+
+// EncryptKey encrypt the private key with a key derived from passphrase by scrypt and seal it with AES-256-GCM,
+// the address is authenticated as additional data so a key file can not be renamed to another account
+func EncryptKey(key *ecdsa.PrivateKey, passphrase string) ([]byte, error) {
+	addr := common.PubKeyToAddress(&key.PublicKey)
+	salt := make([]byte, SaltLength)
+	_, err := rand.Read(salt)
+	if err != nil {
+		return nil, fmt.Errorf("EncryptKey error: %v", err)
+	}
+	derivedKey, err := scrypt.Key([]byte(passphrase), salt, StandardScryptN, StandardScryptR, StandardScryptP, ScryptDKLen)
+	if err != nil {
+		return nil, fmt.Errorf("EncryptKey error: %v", err)
+	}
+	aead, err := newAEAD(derivedKey)
+	if err != nil {
+		return nil, fmt.Errorf("EncryptKey error: %v", err)
+	}
+	nonce := make([]byte, aead.NonceSize())
+	_, err = rand.Read(nonce)
+	if err != nil {
+		return nil, fmt.Errorf("EncryptKey error: %v", err)
+	}
+	cipherText := aead.Seal(nil, nonce, common.PrivateKeyBytes(key), addr.Bytes())
+	keyFile := keyFileJSON{
+		Address: addr.Hex(true),
+		Crypto: cryptoJSON{
+			Cipher:     CipherName,
+			CipherText: hex.EncodeToString(cipherText),
+			Nonce:      hex.EncodeToString(nonce),
+			KDF:        KDFName,
+			KDFParams: kdfParamsJSON{
+				N:     StandardScryptN,
+				R:     StandardScryptR,
+				P:     StandardScryptP,
+				DKLen: ScryptDKLen,
+				Salt:  hex.EncodeToString(salt),
+			},
+		},
+		Version: KeyFileVersion,
+	}
+	return json.MarshalIndent(keyFile, "", "  ")
+}
+
+// DecryptKey returns ErrDecrypt if the passphrase is wrong or the key file was tampered with
+func DecryptKey(keyJSON []byte, passphrase string) (*ecdsa.PrivateKey, error) {
+	var keyFile keyFileJSON
+	err := json.Unmarshal(keyJSON, &keyFile)
+	if err != nil {
+		return nil, fmt.Errorf("DecryptKey error: %v", err)
+	}
+	if keyFile.Version != KeyFileVersion {
+		return nil, fmt.Errorf("DecryptKey error: unsupported version %v", keyFile.Version)
+	}
+	if keyFile.Crypto.Cipher != CipherName || keyFile.Crypto.KDF != KDFName {
+		return nil, fmt.Errorf("DecryptKey error: unsupported cipher %v or kdf %v", keyFile.Crypto.Cipher, keyFile.Crypto.KDF)
+	}
+	addr, err := common.NewAddress(keyFile.Address)
+	if err != nil {
+		return nil, fmt.Errorf("DecryptKey error: %v", err)
+	}
+	// only the parameters EncryptKey writes are accepted, a key file can not make a derivation
+	// arbitrarily expensive or weaken it, and AES-256 needs a 32-byte key
+	params := keyFile.Crypto.KDFParams
+	if params.N != StandardScryptN || params.R != StandardScryptR || params.P != StandardScryptP || params.DKLen != ScryptDKLen {
+		return nil, fmt.Errorf("DecryptKey error: unsupported scrypt parameters n=%v r=%v p=%v dklen=%v",
+			params.N, params.R, params.P, params.DKLen)
+	}
+	salt, err := hex.DecodeString(params.Salt)
+	if err != nil {
+		return nil, fmt.Errorf("DecryptKey error: %v", err)
+	}
+	if len(salt) != SaltLength {
+		return nil, fmt.Errorf("DecryptKey error: salt should be %v bytes", SaltLength)
+	}
+	nonce, err := hex.DecodeString(keyFile.Crypto.Nonce)
+	if err != nil {
+		return nil, fmt.Errorf("DecryptKey error: %v", err)
+	}
+	cipherText, err := hex.DecodeString(keyFile.Crypto.CipherText)
+	if err != nil {
+		return nil, fmt.Errorf("DecryptKey error: %v", err)
+	}
+	derivedKey, err := scrypt.Key([]byte(passphrase), salt, params.N, params.R, params.P, params.DKLen)
+	if err != nil {
+		return nil, fmt.Errorf("DecryptKey error: %v", err)
+	}
+	aead, err := newAEAD(derivedKey)
+	if err != nil {
+		return nil, fmt.Errorf("DecryptKey error: %v", err)
+	}
+	if len(nonce) != aead.NonceSize() {
+		return nil, fmt.Errorf("DecryptKey error: nonce should be %v bytes", aead.NonceSize())
+	}
+	plainText, err := aead.Open(nil, nonce, cipherText, addr.Bytes())
+	if err != nil {
+		return nil, ErrDecrypt
+	}
+	key, err := common.PrivateKeyFromBytes(plainText)
+	if err != nil {
+		return nil, fmt.Errorf("DecryptKey error: %v", err)
+	}
+	if common.PubKeyToAddress(&key.PublicKey) != addr {
+		return nil, fmt.Errorf("DecryptKey error: key does not belong to %v", addr.Hex(true))
+	}
+	return key, nil
+}
+
+func newAEAD(derivedKey []byte) (cipher.AEAD, error) {
+	block, err := aes.NewCipher(derivedKey)
+	if err != nil {
+		return nil, err
+	}
+	return cipher.NewGCM(block)
+}
@@ -0,0 +1,187 @@
+package keystore
+
+import (
+	"crypto/ecdsa"
+	"fmt"
+	"github.com/XiaoYao-0/memory-blockchain/common"
+	"github.com/XiaoYao-0/memory-blockchain/core"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+)
+
+const (
+	KeyStoreDir = "./data/keystore"
+	keyFileExt  = ".json"
+)
+
+// KeyStore manage passphrase-encrypted key files in a directory, one file per account,
+// decrypted keys are only kept in memory between Unlock and Lock
+type KeyStore struct {
+	dir      string
+	mu       sync.Mutex
+	unlocked map[common.Address]*ecdsa.PrivateKey
+}
+
+func NewKeyStore(dir string) (*KeyStore, error) {
+	err := os.MkdirAll(dir, 0700)
+	if err != nil {
+		return nil, fmt.Errorf("NewKeyStore error: %v", err)
+	}
+	return &KeyStore{
+		dir:      dir,
+		unlocked: make(map[common.Address]*ecdsa.PrivateKey),
+	}, nil
+}
+
+func (ks *KeyStore) keyFile(addr common.Address) string {
+	return filepath.Join(ks.dir, addr.Hex(false)+keyFileExt)
+}
+
+// Accounts returns addresses of all key files sorted by address
+func (ks *KeyStore) Accounts() ([]common.Address, error) {
+	files, err := ioutil.ReadDir(ks.dir)
+	if err != nil {
+		return nil, fmt.Errorf("Accounts error: %v", err)
+	}
+	var addrs []common.Address
+	for _, file := range files {
+		name := file.Name()
+		if file.IsDir() || !strings.HasSuffix(name, keyFileExt) {
+			continue
+		}
+		addr, err := common.NewAddress("0x" + strings.TrimSuffix(name, keyFileExt))
+		if err != nil {
+			continue
+		}
+		addrs = append(addrs, addr)
+	}
+	sort.Slice(addrs, func(i, j int) bool {
+		return addrs[i].Hex(false) < addrs[j].Hex(false)
+	})
+	return addrs, nil
+}
+
+func (ks *KeyStore) HasAccount(addr common.Address) bool {
+	_, err := os.Stat(ks.keyFile(addr))
+	return err == nil
+}
+
+// NewAccount generate a new key and store it encrypted with passphrase
+func (ks *KeyStore) NewAccount(passphrase string) (common.Address, error) {
+	key, err := common.GenerateKey()
+	if err != nil {
+		return common.Address{}, fmt.Errorf("NewAccount error: %v", err)
+	}
+	addr, err := ks.ImportKey(key, passphrase)
+	if err != nil {
+		return common.Address{}, fmt.Errorf("NewAccount error: %v", err)
+	}
+	return addr, nil
+}
+
+// ImportKey store an existing key encrypted with passphrase, an existing key file is never overwritten
+func (ks *KeyStore) ImportKey(key *ecdsa.PrivateKey, passphrase string) (common.Address, error) {
+	addr := common.PubKeyToAddress(&key.PublicKey)
+	if ks.HasAccount(addr) {
+		return common.Address{}, fmt.Errorf("ImportKey error: account %v already exists", addr.Hex(true))
+	}
+	keyJSON, err := EncryptKey(key, passphrase)
+	if err != nil {
+		return common.Address{}, fmt.Errorf("ImportKey error: %v", err)
+	}
+	// write to a temporary file first so that a crash never leaves a truncated key file
+	tmp, err := ioutil.TempFile(ks.dir, "."+addr.Hex(false)+".tmp")
+	if err != nil {
+		return common.Address{}, fmt.Errorf("ImportKey error: %v", err)
+	}
+	_, err = tmp.Write(keyJSON)
+	if err == nil {
+		err = tmp.Sync()
+	}
+	closeErr := tmp.Close()
+	if err == nil {
+		err = closeErr
+	}
+	if err == nil {
+		err = os.Rename(tmp.Name(), ks.keyFile(addr))
+	}
+	if err != nil {
+		_ = os.Remove(tmp.Name())
+		return common.Address{}, fmt.Errorf("ImportKey error: %v", err)
+	}
+	return addr, nil
+}
+
+// ExportKey decrypt the key of addr with passphrase
+func (ks *KeyStore) ExportKey(addr common.Address, passphrase string) (*ecdsa.PrivateKey, error) {
+	keyJSON, err := ioutil.ReadFile(ks.keyFile(addr))
+	if os.IsNotExist(err) {
+		return nil, fmt.Errorf("ExportKey error: account %v does not exist", addr.Hex(true))
+	}
+	if err != nil {
+		return nil, fmt.Errorf("ExportKey error: %v", err)
+	}
+	key, err := DecryptKey(keyJSON, passphrase)
+	if err != nil {
+		return nil, fmt.Errorf("ExportKey error: %v", err)
+	}
+	return key, nil
+}
+
+func (ks *KeyStore) Unlock(addr common.Address, passphrase string) error {
+	key, err := ks.ExportKey(addr, passphrase)
+	if err != nil {
+		return fmt.Errorf("Unlock error: %v", err)
+	}
+	ks.mu.Lock()
+	defer ks.mu.Unlock()
+	ks.unlocked[addr] = key
+	return nil
+}
+
+func (ks *KeyStore) Lock(addr common.Address) error {
+	ks.mu.Lock()
+	defer ks.mu.Unlock()
+	if _, ok := ks.unlocked[addr]; !ok {
+		return fmt.Errorf("Lock error: account %v is not unlocked", addr.Hex(true))
+	}
+	delete(ks.unlocked, addr)
+	return nil
+}
+
+func (ks *KeyStore) IsUnlocked(addr common.Address) bool {
+	ks.mu.Lock()
+	defer ks.mu.Unlock()
+	_, ok := ks.unlocked[addr]
+	return ok
+}
+
+// UnlockedKey the key of an unlocked account, for the proof-of-authority engine to seal blocks with
+func (ks *KeyStore) UnlockedKey(addr common.Address) (*ecdsa.PrivateKey, error) {
+	ks.mu.Lock()
+	defer ks.mu.Unlock()
+	key, ok := ks.unlocked[addr]
+	if !ok {
+		return nil, fmt.Errorf("UnlockedKey error: account %v is locked, please unlock it first", addr.Hex(true))
+	}
+	return key, nil
+}
+
+// SignTx sign tx with the unlocked key of tx.From
+func (ks *KeyStore) SignTx(tx *core.Transaction) error {
+	ks.mu.Lock()
+	key, ok := ks.unlocked[tx.From]
+	ks.mu.Unlock()
+	if !ok {
+		return fmt.Errorf("SignTx error: account %v is locked, please unlock it first", tx.From.Hex(true))
+	}
+	err := tx.Sign(key)
+	if err != nil {
+		return fmt.Errorf("SignTx error: %v", err)
+	}
+	return nil
+}